@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+)
+
+type blockCipherKAT struct {
+	source     string
+	key        string
+	plaintext  string
+	ciphertext string
+}
+
+var aesKATs = []blockCipherKAT{
+	{"FIPS-197 appendix B", "2b7e151628aed2a6abf7158809cf4f3c", "3243f6a8885a308d313198a2e0370734", "3925841d02dc09fbdc118597196a0b32"},
+	{"FIPS-197 C.1", "000102030405060708090a0b0c0d0e0f", "00112233445566778899aabbccddeeff", "69c4e0d86a7b0430d8cdb78070b4c55a"},
+	{"FIPS-197 C.2", "000102030405060708090a0b0c0d0e0f1011121314151617", "00112233445566778899aabbccddeeff", "dda97ca4864cdfe06eaf70a0ec0d7191"},
+	{"FIPS-197 C.3", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "00112233445566778899aabbccddeeff", "8ea2b7ca516745bfeafc49904b496089"},
+	{"AESAVS GFSbox-128 #1", "00000000000000000000000000000000", "f34481ec3cc627bacd5dc3fb08f273e6", "0336763e966d92595a567cc9ce537f5e"},
+}
+
+func TestAESKnownAnswers(t *testing.T) {
+	testBlockCipherKATs(t, map[string]func([]byte) (cipher.Block, error){
+		"crypto/aes":    aes.NewCipher,
+		"table AES":     newTableAES,
+		"bitsliced AES": newBitslicedAES,
+	}, aesKATs)
+}
+
+func testBlockCipherKATs(t *testing.T, ciphers map[string]func([]byte) (cipher.Block, error), vectors []blockCipherKAT) {
+	for name, newCipher := range ciphers {
+		for _, v := range vectors {
+			t.Run(name+"/"+v.source, func(t *testing.T) {
+				key, plaintext, ciphertext := mustDecodeHex(v.key), mustDecodeHex(v.plaintext), mustDecodeHex(v.ciphertext)
+				block, err := newCipher(key)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				out := make([]byte, len(plaintext))
+				block.Encrypt(out, plaintext)
+				if !bytes.Equal(out, ciphertext) {
+					t.Errorf("Encrypt = %x, want %x", out, ciphertext)
+				}
+				block.Decrypt(out, ciphertext)
+				if !bytes.Equal(out, plaintext) {
+					t.Errorf("Decrypt = %x, want %x", out, plaintext)
+				}
+			})
+		}
+	}
+}
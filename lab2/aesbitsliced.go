@@ -0,0 +1,324 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"math/bits"
+)
+
+// BitslicedAES computes AES without any table lookup or secret-dependent
+// branch. The state is stored as eight 16-bit planes, plane b holding bit b of
+// each of the 16 state bytes (byte i = 4*column + row at bit i), and the
+// S-box is the 113-gate Boyar-Peralta circuit applied to all bytes at once.
+// Production code (BearSSL's aes_ct64) packs several blocks into wider words;
+// one block per call keeps this close to the cipher.Block interface.
+type BitslicedAES struct {
+	roundKeys [][8]uint16
+}
+
+func newBitslicedAES(key []byte) (cipher.Block, error) {
+	rounds, err := aesRounds(key)
+	if err != nil {
+		return nil, err
+	}
+
+	w := aesExpandKey(key, rounds, aesSubWordBitsliced)
+	roundKeys := make([][8]uint16, rounds+1)
+	block := make([]byte, 16)
+	for r := range roundKeys {
+		for j := range 4 {
+			binary.BigEndian.PutUint32(block[4*j:], w[4*r+j])
+		}
+		roundKeys[r] = bitslicePack(block)
+	}
+	return &BitslicedAES{roundKeys: roundKeys}, nil
+}
+
+func aesSubWordBitsliced(w uint32) uint32 {
+	var b [16]byte
+	binary.BigEndian.PutUint32(b[:], w)
+	q := bitslicePack(b[:])
+	bitsliceSbox(&q)
+	bitsliceUnpack(&q, b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func (c *BitslicedAES) BlockSize() int { return 16 }
+
+func (c *BitslicedAES) Encrypt(dst, src []byte) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("aes: input not full block")
+	}
+	rounds := len(c.roundKeys) - 1
+
+	q := bitslicePack(src)
+	bitsliceAddRoundKey(&q, &c.roundKeys[0])
+	for r := 1; r < rounds; r++ {
+		bitsliceSbox(&q)
+		bitsliceShiftRows(&q)
+		bitsliceMixColumns(&q)
+		bitsliceAddRoundKey(&q, &c.roundKeys[r])
+	}
+	bitsliceSbox(&q)
+	bitsliceShiftRows(&q)
+	bitsliceAddRoundKey(&q, &c.roundKeys[rounds])
+	bitsliceUnpack(&q, dst)
+}
+
+func (c *BitslicedAES) Decrypt(dst, src []byte) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("aes: input not full block")
+	}
+	rounds := len(c.roundKeys) - 1
+
+	q := bitslicePack(src)
+	bitsliceAddRoundKey(&q, &c.roundKeys[rounds])
+	for r := rounds - 1; r > 0; r-- {
+		bitsliceInvShiftRows(&q)
+		bitsliceInvSbox(&q)
+		bitsliceAddRoundKey(&q, &c.roundKeys[r])
+		bitsliceInvMixColumns(&q)
+	}
+	bitsliceInvShiftRows(&q)
+	bitsliceInvSbox(&q)
+	bitsliceAddRoundKey(&q, &c.roundKeys[0])
+	bitsliceUnpack(&q, dst)
+}
+
+func bitslicePack(block []byte) [8]uint16 {
+	var q [8]uint16
+	for i := range 16 {
+		for b := range 8 {
+			q[b] |= uint16(block[i]>>b&1) << i
+		}
+	}
+	return q
+}
+
+func bitsliceUnpack(q *[8]uint16, block []byte) {
+	for i := range 16 {
+		var v byte
+		for b := range 8 {
+			v |= byte(q[b]>>i&1) << b
+		}
+		block[i] = v
+	}
+}
+
+func bitsliceAddRoundKey(q, key *[8]uint16) {
+	for b := range q {
+		q[b] ^= key[b]
+	}
+}
+
+// bitsliceShiftRows rotates row r left by r columns. Row r sits at bits
+// r, r+4, r+8 and r+12 of every plane, so that is a 16-bit rotation by 4r.
+func bitsliceShiftRows(q *[8]uint16) {
+	for b, x := range q {
+		q[b] = x&0x1111 | bits.RotateLeft16(x&0x2222, -4) | bits.RotateLeft16(x&0x4444, -8) | bits.RotateLeft16(x&0x8888, -12)
+	}
+}
+
+func bitsliceInvShiftRows(q *[8]uint16) {
+	for b, x := range q {
+		q[b] = x&0x1111 | bits.RotateLeft16(x&0x2222, 4) | bits.RotateLeft16(x&0x4444, 8) | bits.RotateLeft16(x&0x8888, 12)
+	}
+}
+
+// rotateRows1 moves row r+1 of every column into row r.
+func rotateRows1(x uint16) uint16 {
+	return x>>1&0x7777 | x<<3&0x8888
+}
+
+func rotateRows2(x uint16) uint16 {
+	return x>>2&0x3333 | x<<2&0xcccc
+}
+
+// bitsliceDouble multiplies every byte by x: the planes shift up by one and
+// the top bit is reduced with 0x1b (bits 0, 1, 3 and 4).
+func bitsliceDouble(q *[8]uint16) [8]uint16 {
+	return [8]uint16{q[7], q[0] ^ q[7], q[1], q[2] ^ q[7], q[3] ^ q[7], q[4], q[5], q[6]}
+}
+
+// bitsliceMixColumns computes 2a_r ^ 3a_{r+1} ^ a_{r+2} ^ a_{r+3} for every
+// row, written as 2(a_r ^ a_{r+1}) ^ a_{r+1} ^ (a_{r+2} ^ a_{r+3}).
+func bitsliceMixColumns(q *[8]uint16) {
+	var t, r1 [8]uint16
+	for b, x := range q {
+		r1[b] = rotateRows1(x)
+		t[b] = x ^ r1[b]
+	}
+	doubled := bitsliceDouble(&t)
+	for b := range q {
+		q[b] = doubled[b] ^ r1[b] ^ rotateRows2(t[b])
+	}
+}
+
+// bitsliceInvMixColumns uses the factorisation of InvMixColumns into a
+// cheap preprocessing step, a_r ^= 4(a_r ^ a_{r+2}), followed by MixColumns.
+func bitsliceInvMixColumns(q *[8]uint16) {
+	var t [8]uint16
+	for b, x := range q {
+		t[b] = x ^ rotateRows2(x)
+	}
+	t = bitsliceDouble(&t)
+	t = bitsliceDouble(&t)
+	for b := range q {
+		q[b] ^= t[b]
+	}
+	bitsliceMixColumns(q)
+}
+
+// bitsliceInvSbox uses S^-1 = L . S . L with the affine map
+// L(y) = A^-1(y ^ 0x63), since S(x) = A(x^-1) ^ 0x63 gives
+// x^-1 = L(S(x)) and S^-1(y) = (L(y))^-1.
+func bitsliceInvSbox(q *[8]uint16) {
+	bitsliceInvAffine(q)
+	bitsliceSbox(q)
+	bitsliceInvAffine(q)
+}
+
+func bitsliceInvAffine(q *[8]uint16) {
+	var y [8]uint16
+	for b := range q {
+		y[b] = q[b]
+		if 0x63>>b&1 != 0 {
+			y[b] = ^y[b]
+		}
+	}
+	for b := range q {
+		q[b] = y[(b+2)%8] ^ y[(b+5)%8] ^ y[(b+7)%8]
+	}
+}
+
+// bitsliceSbox is the circuit by Boyar and Peralta ("A depth-16 circuit for
+// the AES S-box", 2011): a linear layer, a shared GF(2^4) inversion and a
+// final linear layer. x0 is the most significant bit.
+func bitsliceSbox(q *[8]uint16) {
+	x0, x1, x2, x3, x4, x5, x6, x7 := q[7], q[6], q[5], q[4], q[3], q[2], q[1], q[0]
+
+	// top linear transformation
+	y14 := x3 ^ x5
+	y13 := x0 ^ x6
+	y9 := x0 ^ x3
+	y8 := x0 ^ x5
+	t0 := x1 ^ x2
+	y1 := t0 ^ x7
+	y4 := y1 ^ x3
+	y12 := y13 ^ y14
+	y2 := y1 ^ x0
+	y5 := y1 ^ x6
+	y3 := y5 ^ y8
+	t1 := x4 ^ y12
+	y15 := t1 ^ x5
+	y20 := t1 ^ x1
+	y6 := y15 ^ x7
+	y10 := y15 ^ t0
+	y11 := y20 ^ y9
+	y7 := x7 ^ y11
+	y17 := y10 ^ y11
+	y19 := y10 ^ y8
+	y16 := t0 ^ y11
+	y21 := y13 ^ y16
+	y18 := x0 ^ y16
+
+	// non-linear section
+	t2 := y12 & y15
+	t3 := y3 & y6
+	t4 := t3 ^ t2
+	t5 := y4 & x7
+	t6 := t5 ^ t2
+	t7 := y13 & y16
+	t8 := y5 & y1
+	t9 := t8 ^ t7
+	t10 := y2 & y7
+	t11 := t10 ^ t7
+	t12 := y9 & y11
+	t13 := y14 & y17
+	t14 := t13 ^ t12
+	t15 := y8 & y10
+	t16 := t15 ^ t12
+	t17 := t4 ^ t14
+	t18 := t6 ^ t16
+	t19 := t9 ^ t14
+	t20 := t11 ^ t16
+	t21 := t17 ^ y20
+	t22 := t18 ^ y19
+	t23 := t19 ^ y21
+	t24 := t20 ^ y18
+
+	t25 := t21 ^ t22
+	t26 := t21 & t23
+	t27 := t24 ^ t26
+	t28 := t25 & t27
+	t29 := t28 ^ t22
+	t30 := t23 ^ t24
+	t31 := t22 ^ t26
+	t32 := t31 & t30
+	t33 := t32 ^ t24
+	t34 := t23 ^ t33
+	t35 := t27 ^ t33
+	t36 := t24 & t35
+	t37 := t36 ^ t34
+	t38 := t27 ^ t36
+	t39 := t29 & t38
+	t40 := t25 ^ t39
+
+	t41 := t40 ^ t37
+	t42 := t29 ^ t33
+	t43 := t29 ^ t40
+	t44 := t33 ^ t37
+	t45 := t42 ^ t41
+	z0 := t44 & y15
+	z1 := t37 & y6
+	z2 := t33 & x7
+	z3 := t43 & y16
+	z4 := t40 & y1
+	z5 := t29 & y7
+	z6 := t42 & y11
+	z7 := t45 & y17
+	z8 := t41 & y10
+	z9 := t44 & y12
+	z10 := t37 & y3
+	z11 := t33 & y4
+	z12 := t43 & y13
+	z13 := t40 & y5
+	z14 := t29 & y2
+	z15 := t42 & y9
+	z16 := t45 & y14
+	z17 := t41 & y8
+
+	// bottom linear transformation
+	t46 := z15 ^ z16
+	t47 := z10 ^ z11
+	t48 := z5 ^ z13
+	t49 := z9 ^ z10
+	t50 := z2 ^ z12
+	t51 := z2 ^ z5
+	t52 := z7 ^ z8
+	t53 := z0 ^ z3
+	t54 := z6 ^ z7
+	t55 := z16 ^ z17
+	t56 := z12 ^ t48
+	t57 := t50 ^ t53
+	t58 := z4 ^ t46
+	t59 := z3 ^ t54
+	t60 := t46 ^ t57
+	t61 := z14 ^ t57
+	t62 := t52 ^ t58
+	t63 := t49 ^ t58
+	t64 := z4 ^ t59
+	t65 := t61 ^ t62
+	t66 := z1 ^ t63
+	s0 := t59 ^ t63
+	s6 := t56 ^ ^t62
+	s7 := t48 ^ ^t60
+	t67 := t64 ^ t65
+	s3 := t53 ^ t66
+	s4 := t51 ^ t66
+	s5 := t47 ^ t65
+	s1 := t64 ^ ^s3
+	s2 := t55 ^ ^t67
+
+	q[7], q[6], q[5], q[4], q[3], q[2], q[1], q[0] = s0, s1, s2, s3, s4, s5, s6, s7
+}
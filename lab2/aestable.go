@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// TableAES is the classic 32-bit T-table AES from the Rijndael reference
+// code: every round is 16 table lookups and XORs. The lookups depend on key
+// and data, so it leaks through the cache; crypto/aes only uses this style
+// when the CPU has no AES instructions.
+type TableAES struct {
+	enc []uint32
+	dec []uint32
+}
+
+var (
+	aesSbox    [256]byte
+	aesInvSbox [256]byte
+	// te[0][x] is the column (2s, s, s, 3s) for s = S(x); te[1..3] are the
+	// same words rotated so that no shifts are needed inside a round
+	te [4][256]uint32
+	// td[0][x] is (14s, 9s, 13s, 11s) for s = S^-1(x)
+	td [4][256]uint32
+)
+
+func init() {
+	// p steps through every non-zero element as a power of the generator 3
+	// and q through the same powers of 3^-1, so q is always p^-1
+	p, q := byte(1), byte(1)
+	for {
+		p = p ^ gfDouble(p)
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+
+		// S(p) = affine(p^-1) = q ^ rotl(q,1..4) ^ 0x63
+		s := q ^ bits.RotateLeft8(q, 1) ^ bits.RotateLeft8(q, 2) ^ bits.RotateLeft8(q, 3) ^ bits.RotateLeft8(q, 4) ^ 0x63
+		aesSbox[p] = s
+		if p == 1 {
+			break
+		}
+	}
+	aesSbox[0] = 0x63
+
+	for x := range 256 {
+		s := aesSbox[x]
+		aesInvSbox[s] = byte(x)
+	}
+
+	for x := range 256 {
+		s := aesSbox[x]
+		word := uint32(gfMul(s, 2))<<24 | uint32(s)<<16 | uint32(s)<<8 | uint32(gfMul(s, 3))
+		i := aesInvSbox[x]
+		invWord := uint32(gfMul(i, 14))<<24 | uint32(gfMul(i, 9))<<16 | uint32(gfMul(i, 13))<<8 | uint32(gfMul(i, 11))
+		for r := range 4 {
+			te[r][x] = bits.RotateLeft32(word, -8*r)
+			td[r][x] = bits.RotateLeft32(invWord, -8*r)
+		}
+	}
+}
+
+// gfDouble multiplies by x in GF(2^8) modulo x^8 + x^4 + x^3 + x + 1.
+func gfDouble(b byte) byte {
+	return b<<1 ^ byte(int8(b)>>7)&0x1b
+}
+
+func gfMul(a, b byte) byte {
+	var product byte
+	for ; b != 0; b >>= 1 {
+		if b&1 != 0 {
+			product ^= a
+		}
+		a = gfDouble(a)
+	}
+	return product
+}
+
+func newTableAES(key []byte) (cipher.Block, error) {
+	rounds, err := aesRounds(key)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := aesExpandKey(key, rounds, aesSubWordTable)
+
+	// equivalent inverse cipher: the round keys in reverse order, with
+	// InvMixColumns applied to all but the first and last
+	dec := make([]uint32, len(enc))
+	n := len(enc)
+	for i := 0; i < n; i += 4 {
+		for j := range 4 {
+			x := enc[n-i-4+j]
+			if i > 0 && i+4 < n {
+				x = td[0][aesSbox[x>>24]] ^ td[1][aesSbox[x>>16&0xff]] ^ td[2][aesSbox[x>>8&0xff]] ^ td[3][aesSbox[x&0xff]]
+			}
+			dec[i+j] = x
+		}
+	}
+
+	return &TableAES{enc: enc, dec: dec}, nil
+}
+
+func aesRounds(key []byte) (int, error) {
+	switch len(key) {
+	case 16, 24, 32:
+		return len(key)/4 + 6, nil
+	}
+	return 0, fmt.Errorf("invalid AES key size %d", len(key))
+}
+
+// aesExpandKey is the FIPS-197 key schedule; subWord is passed in so that
+// the bitsliced implementation can keep the S-box lookups constant time.
+func aesExpandKey(key []byte, rounds int, subWord func(uint32) uint32) []uint32 {
+	nk := len(key) / 4
+	w := make([]uint32, 4*(rounds+1))
+	for i := range nk {
+		w[i] = binary.BigEndian.Uint32(key[4*i:])
+	}
+
+	rcon := byte(1)
+	for i := nk; i < len(w); i++ {
+		t := w[i-1]
+		if i%nk == 0 {
+			t = subWord(bits.RotateLeft32(t, 8)) ^ uint32(rcon)<<24
+			rcon = gfDouble(rcon)
+		} else if nk > 6 && i%nk == 4 {
+			t = subWord(t)
+		}
+		w[i] = w[i-nk] ^ t
+	}
+	return w
+}
+
+func aesSubWordTable(w uint32) uint32 {
+	return uint32(aesSbox[w>>24])<<24 | uint32(aesSbox[w>>16&0xff])<<16 | uint32(aesSbox[w>>8&0xff])<<8 | uint32(aesSbox[w&0xff])
+}
+
+func (c *TableAES) BlockSize() int { return 16 }
+
+func (c *TableAES) Encrypt(dst, src []byte) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("aes: input not full block")
+	}
+	xk := c.enc
+	s0 := binary.BigEndian.Uint32(src[0:]) ^ xk[0]
+	s1 := binary.BigEndian.Uint32(src[4:]) ^ xk[1]
+	s2 := binary.BigEndian.Uint32(src[8:]) ^ xk[2]
+	s3 := binary.BigEndian.Uint32(src[12:]) ^ xk[3]
+
+	k := 4
+	var t0, t1, t2, t3 uint32
+	for r := 0; r < len(xk)/4-2; r++ {
+		t0 = xk[k+0] ^ te[0][s0>>24] ^ te[1][s1>>16&0xff] ^ te[2][s2>>8&0xff] ^ te[3][s3&0xff]
+		t1 = xk[k+1] ^ te[0][s1>>24] ^ te[1][s2>>16&0xff] ^ te[2][s3>>8&0xff] ^ te[3][s0&0xff]
+		t2 = xk[k+2] ^ te[0][s2>>24] ^ te[1][s3>>16&0xff] ^ te[2][s0>>8&0xff] ^ te[3][s1&0xff]
+		t3 = xk[k+3] ^ te[0][s3>>24] ^ te[1][s0>>16&0xff] ^ te[2][s1>>8&0xff] ^ te[3][s2&0xff]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+		k += 4
+	}
+
+	// the last round has no MixColumns
+	t0 = aesSubShift(s0, s1, s2, s3, aesSbox[:]) ^ xk[k+0]
+	t1 = aesSubShift(s1, s2, s3, s0, aesSbox[:]) ^ xk[k+1]
+	t2 = aesSubShift(s2, s3, s0, s1, aesSbox[:]) ^ xk[k+2]
+	t3 = aesSubShift(s3, s0, s1, s2, aesSbox[:]) ^ xk[k+3]
+
+	binary.BigEndian.PutUint32(dst[0:], t0)
+	binary.BigEndian.PutUint32(dst[4:], t1)
+	binary.BigEndian.PutUint32(dst[8:], t2)
+	binary.BigEndian.PutUint32(dst[12:], t3)
+}
+
+func (c *TableAES) Decrypt(dst, src []byte) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("aes: input not full block")
+	}
+	xk := c.dec
+	s0 := binary.BigEndian.Uint32(src[0:]) ^ xk[0]
+	s1 := binary.BigEndian.Uint32(src[4:]) ^ xk[1]
+	s2 := binary.BigEndian.Uint32(src[8:]) ^ xk[2]
+	s3 := binary.BigEndian.Uint32(src[12:]) ^ xk[3]
+
+	k := 4
+	var t0, t1, t2, t3 uint32
+	for r := 0; r < len(xk)/4-2; r++ {
+		t0 = xk[k+0] ^ td[0][s0>>24] ^ td[1][s3>>16&0xff] ^ td[2][s2>>8&0xff] ^ td[3][s1&0xff]
+		t1 = xk[k+1] ^ td[0][s1>>24] ^ td[1][s0>>16&0xff] ^ td[2][s3>>8&0xff] ^ td[3][s2&0xff]
+		t2 = xk[k+2] ^ td[0][s2>>24] ^ td[1][s1>>16&0xff] ^ td[2][s0>>8&0xff] ^ td[3][s3&0xff]
+		t3 = xk[k+3] ^ td[0][s3>>24] ^ td[1][s2>>16&0xff] ^ td[2][s1>>8&0xff] ^ td[3][s0&0xff]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+		k += 4
+	}
+
+	t0 = aesSubShift(s0, s3, s2, s1, aesInvSbox[:]) ^ xk[k+0]
+	t1 = aesSubShift(s1, s0, s3, s2, aesInvSbox[:]) ^ xk[k+1]
+	t2 = aesSubShift(s2, s1, s0, s3, aesInvSbox[:]) ^ xk[k+2]
+	t3 = aesSubShift(s3, s2, s1, s0, aesInvSbox[:]) ^ xk[k+3]
+
+	binary.BigEndian.PutUint32(dst[0:], t0)
+	binary.BigEndian.PutUint32(dst[4:], t1)
+	binary.BigEndian.PutUint32(dst[8:], t2)
+	binary.BigEndian.PutUint32(dst[12:], t3)
+}
+
+// aesSubShift builds one output column of the final round from byte r of the
+// r-th input word.
+func aesSubShift(a, b, c, d uint32, sbox []byte) uint32 {
+	return uint32(sbox[a>>24])<<24 | uint32(sbox[b>>16&0xff])<<16 | uint32(sbox[c>>8&0xff])<<8 | uint32(sbox[d&0xff])
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+const compareAlpha = 0.05
+
+type ResultFile struct {
+	keyName     string
+	keys        []string
+	medians     map[string]time.Duration
+	samples     map[string][]time.Duration
+	environment string
+}
+
+type Comparison struct {
+	file         string
+	key          string
+	oldMedian    time.Duration
+	newMedian    time.Duration
+	deltaPercent float64
+	pValue       float64
+	verdict      string
+}
+
+func runCompare(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	threshold := flags.Float64("threshold", 5, "flag median changes larger than this many percent")
+	outDir := flags.String("out", "results/compare", "directory for the comparison table and plots")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: lab2 compare [flags] <old results dir> <new results dir>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	oldDir, newDir := flags.Arg(0), flags.Arg(1)
+	oldResults, err := loadResultDir(oldDir)
+	if err != nil {
+		log.Fatalf("error loading %s: %v", oldDir, err)
+	}
+	newResults, err := loadResultDir(newDir)
+	if err != nil {
+		log.Fatalf("error loading %s: %v", newDir, err)
+	}
+
+	comparisons := compareResults(oldResults, newResults, *threshold)
+
+	printEnvironments(oldDir, oldResults)
+	printEnvironments(newDir, newResults)
+	fmt.Println()
+	printComparisons(comparisons)
+
+	exportComparisons(comparisons, filepath.Join(*outDir, "compare.csv"))
+	drawComparisonPlots(oldResults, newResults, filepath.Join(*outDir, "plots"))
+}
+
+// loadResultDir reads every summary CSV below dir (as written by exportAER,
+// exportAKGR and exportKDF) together with its raw/ sample dump, if any.
+func loadResultDir(dir string) (map[string]*ResultFile, error) {
+	results := map[string]*ResultFile{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); name == "raw" || name == "plots" || name == "compare" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".csv" {
+			return nil
+		}
+
+		result, err := loadResultFile(path)
+		if err != nil {
+			log.Printf("  [!] Skipping %s: %v", path, err)
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		results[filepath.ToSlash(rel)] = result
+		return nil
+	})
+
+	return results, err
+}
+
+func loadResultFile(path string) (*ResultFile, error) {
+	header, rows, env, err := readCsvTable(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(header) <= 2 || !strings.HasPrefix(header[2], "Median") {
+		return nil, fmt.Errorf("not a benchmark summary: expected a median column")
+	}
+
+	result := &ResultFile{
+		keyName: header[0],
+		medians: map[string]time.Duration{},
+		samples: map[string][]time.Duration{},
+	}
+	if env != nil {
+		result.environment = env.summary()
+	}
+
+	for i, row := range rows {
+		if len(row) <= 2 {
+			return nil, fmt.Errorf("invalid row %d: expected at least 3 columns, got %d", i, len(row))
+		}
+		median, err := parseDurationColumn(row[2])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing median (row %d): %w", i, err)
+		}
+		result.keys = append(result.keys, row[0])
+		result.medians[row[0]] = median
+	}
+
+	_, rawRows, _, err := readCsvTable(rawSamplesPath(path))
+	if err != nil {
+		return result, nil
+	}
+	for i, row := range rawRows {
+		if len(row) < 4 || row[1] != "warm" {
+			continue
+		}
+		sample, err := parseDurationColumn(row[3])
+		if err != nil {
+			return nil, fmt.Errorf("error parsing raw sample (row %d): %w", i, err)
+		}
+		result.samples[row[0]] = append(result.samples[row[0]], sample)
+	}
+
+	return result, nil
+}
+
+func compareResults(oldResults, newResults map[string]*ResultFile, threshold float64) []*Comparison {
+	files := make([]string, 0, len(oldResults))
+	for file := range oldResults {
+		if _, ok := newResults[file]; ok {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+
+	comparisons := []*Comparison{}
+	for _, file := range files {
+		oldResult, newResult := oldResults[file], newResults[file]
+		for _, key := range oldResult.keys {
+			newMedian, ok := newResult.medians[key]
+			if !ok {
+				continue
+			}
+
+			c := &Comparison{
+				file:      file,
+				key:       key,
+				oldMedian: oldResult.medians[key],
+				newMedian: newMedian,
+				pValue:    math.NaN(),
+			}
+			if c.oldMedian > 0 {
+				c.deltaPercent = float64(c.newMedian-c.oldMedian) / float64(c.oldMedian) * 100
+			}
+
+			oldSamples, newSamples := oldResult.samples[key], newResult.samples[key]
+			if len(oldSamples) > 0 && len(newSamples) > 0 {
+				c.pValue = mannWhitneyU(oldSamples, newSamples)
+			}
+			c.verdict = comparisonVerdict(c, threshold)
+
+			comparisons = append(comparisons, c)
+		}
+	}
+	return comparisons
+}
+
+// comparisonVerdict marks changes above the threshold. Without raw samples
+// there is nothing to test, so such changes get a question mark.
+func comparisonVerdict(c *Comparison, threshold float64) string {
+	if math.Abs(c.deltaPercent) < threshold {
+		return ""
+	}
+
+	verdict := "improvement"
+	if c.deltaPercent > 0 {
+		verdict = "REGRESSION"
+	}
+
+	switch {
+	case math.IsNaN(c.pValue):
+		return verdict + "?"
+	case c.pValue < compareAlpha:
+		return verdict
+	default:
+		return ""
+	}
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test,
+// using the normal approximation with tie and continuity corrections.
+func mannWhitneyU(a, b []time.Duration) float64 {
+	n1, n2 := len(a), len(b)
+	n := n1 + n2
+
+	type sample struct {
+		value time.Duration
+		first bool
+	}
+	combined := make([]sample, 0, n)
+	for _, v := range a {
+		combined = append(combined, sample{v, true})
+	}
+	for _, v := range b {
+		combined = append(combined, sample{v, false})
+	}
+	slices.SortFunc(combined, func(x, y sample) int { return cmp.Compare(x.value, y.value) })
+
+	rankSumA := 0.0
+	tieCorrection := 0.0
+	for i := 0; i < n; {
+		j := i
+		for j < n && combined[j].value == combined[i].value {
+			j++
+		}
+		// average rank of the tied group (ranks are 1-based)
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if combined[k].first {
+				rankSumA += rank
+			}
+		}
+		t := float64(j - i)
+		tieCorrection += t*t*t - t
+		i = j
+	}
+
+	u := rankSumA - float64(n1*(n1+1))/2
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * (float64(n+1) - tieCorrection/float64(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+func printEnvironments(dir string, results map[string]*ResultFile) {
+	environments := map[string]bool{}
+	for _, result := range results {
+		if result.environment != "" {
+			environments[result.environment] = true
+		}
+	}
+
+	fmt.Printf("%s:\n", dir)
+	if len(environments) == 0 {
+		fmt.Println("  (no environment metadata)")
+	}
+	for environment := range environments {
+		fmt.Printf("  %s\n", environment)
+	}
+}
+
+func printComparisons(comparisons []*Comparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "File\tKey\tOld median\tNew median\tDelta\tp-value\t")
+	for _, c := range comparisons {
+		fmt.Fprintf(w, "%s\t%s\t%v\t%v\t%+.2f%%\t%s\t%s\n",
+			c.file, c.key, c.oldMedian, c.newMedian, c.deltaPercent, formatPValue(c.pValue), c.verdict)
+	}
+	w.Flush()
+
+	regressions := 0
+	for _, c := range comparisons {
+		if strings.HasPrefix(c.verdict, "REGRESSION") {
+			regressions++
+		}
+	}
+	fmt.Printf("\n%d comparisons, %d regressions\n", len(comparisons), regressions)
+
+	untested := 0
+	for _, c := range comparisons {
+		if math.IsNaN(c.pValue) {
+			untested++
+		}
+	}
+	if untested > 0 {
+		fmt.Printf("%d comparisons have no p-value: significance testing needs raw samples, so run both benchmarks with -raw\n", untested)
+	}
+}
+
+func formatPValue(p float64) string {
+	if math.IsNaN(p) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.4f", p)
+}
+
+func exportComparisons(comparisons []*Comparison, filename string) {
+	records := [][]string{{"File", "Key", "Old median (ns)", "New median (ns)", "Delta (%)", "p-value", "Verdict"}}
+	for _, c := range comparisons {
+		records = append(records, []string{
+			c.file,
+			c.key,
+			fmt.Sprint(c.oldMedian.Nanoseconds()),
+			fmt.Sprint(c.newMedian.Nanoseconds()),
+			fmt.Sprintf("%.4f", c.deltaPercent),
+			formatPValue(c.pValue),
+			c.verdict,
+		})
+	}
+	// the compared runs keep their own environments, this one would only
+	// describe the machine that ran the comparison
+	writeCSV(filename, records)
+	os.Remove(environmentPath(filename))
+}
+
+func drawComparisonPlots(oldResults, newResults map[string]*ResultFile, dir string) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("  [!] ERROR creating plot directory %s: %v", dir, err)
+		return
+	}
+
+	for file, oldResult := range oldResults {
+		newResult, ok := newResults[file]
+		if !ok {
+			continue
+		}
+
+		oldPoints, err := resultFilePoints(oldResult)
+		if err != nil {
+			log.Printf("  [!] Skipping comparison plot for %s: %v", file, err)
+			continue
+		}
+		newPoints, err := resultFilePoints(newResult)
+		if err != nil {
+			log.Printf("  [!] Skipping comparison plot for %s: %v", file, err)
+			continue
+		}
+
+		name := strings.ReplaceAll(strings.TrimSuffix(file, ".csv"), "/", "_")
+		config := PlotConfig{Title: "Comparison: " + file, XLabel: oldResult.keyName, YLabel: "Median Time (μs)", Filepath: filepath.Join(dir, name+".png")}
+		drawAndSavePlot(config, []SeriesData{{Name: "old", Points: oldPoints}, {Name: "new", Points: newPoints}})
+	}
+}
+
+func resultFilePoints(result *ResultFile) (plotter.XYs, error) {
+	pts := make(plotter.XYs, 0, len(result.keys))
+	for _, key := range result.keys {
+		x, err := parseFloatKey(key)
+		if err != nil {
+			return nil, err
+		}
+		pts = append(pts, plotter.XY{X: x, Y: float64(result.medians[key]) / float64(time.Microsecond)})
+	}
+	return pts, nil
+}
+
+func parseFloatKey(key string) (float64, error) {
+	var x float64
+	if _, err := fmt.Sscan(key, &x); err != nil {
+		return 0, fmt.Errorf("non-numeric key %q", key)
+	}
+	return x, nil
+}
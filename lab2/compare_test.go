@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMannWhitneyU(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b []time.Duration
+		want float64
+	}{
+		// scipy.stats.mannwhitneyu documentation example, method="asymptotic"
+		{"no ties", []time.Duration{19, 22, 16, 29, 24}, []time.Duration{20, 11, 17, 12}, 0.11134688653314041},
+		// ranks 1, 3, 3, 5.5, 8 give U = 6.5; ties of 3 and 2 and 2 make
+		// sum(t^3 - t) = 36, so the variance is 30/12 * (12 - 36/110) and
+		// z = (|6.5 - 15| - 0.5) / sqrt(29.1818...) = 1.48093
+		{"ties", []time.Duration{1, 2, 2, 3, 5}, []time.Duration{2, 3, 4, 4, 6, 7}, 0.13862587987892772},
+		{"identical", []time.Duration{5, 5, 5}, []time.Duration{5, 5, 5}, 1},
+		{"equal medians", []time.Duration{1, 2, 3}, []time.Duration{1, 2, 3}, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := mannWhitneyU(c.a, c.b); math.Abs(got-c.want) > 1e-12 {
+				t.Errorf("mannWhitneyU(a, b) = %.17g, want %.17g", got, c.want)
+			}
+			if got := mannWhitneyU(c.b, c.a); math.Abs(got-c.want) > 1e-12 {
+				t.Errorf("mannWhitneyU(b, a) = %.17g, want %.17g", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"testing"
+)
+
+var desKATs = []blockCipherKAT{
+	{"SP 800-17 variable plaintext #1", "0101010101010101", "8000000000000000", "95f8a5e5dd31d900"},
+	{"SP 800-17 variable plaintext #2", "0101010101010101", "4000000000000000", "dd7f121ca5015619"},
+	{"SP 800-17 variable key #1", "8001010101010101", "0000000000000000", "95a8d72813daa94d"},
+	{"FIPS 46 worked example", "133457799bbcdff1", "0123456789abcdef", "85e813540f0ab405"},
+}
+
+var tripleDESKATs = []blockCipherKAT{
+	{"SP 800-67 example block 1", "0123456789abcdef23456789abcdef01456789abcdef0123", "5468652071756663", "a826fd8ce53b855f"},
+	{"SP 800-67 example block 2", "0123456789abcdef23456789abcdef01456789abcdef0123", "6b2062726f776e20", "cce21c8112256fe6"},
+	{"SP 800-67 example block 3", "0123456789abcdef23456789abcdef01456789abcdef0123", "666f78206a756d70", "68d5c05dd9b6b900"},
+}
+
+func TestDESKnownAnswers(t *testing.T) {
+	testBlockCipherKATs(t, map[string]func([]byte) (cipher.Block, error){
+		"crypto/des":  des.NewCipher,
+		"scratch DES": newScratchDES,
+	}, desKATs)
+}
+
+func TestTripleDESKnownAnswers(t *testing.T) {
+	testBlockCipherKATs(t, map[string]func([]byte) (cipher.Block, error){
+		"crypto/des":   des.NewTripleDESCipher,
+		"scratch 3DES": newScratchTripleDES,
+	}, tripleDESKATs)
+}
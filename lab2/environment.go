@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/cpu"
+)
+
+type Environment struct {
+	GoVersion   string    `json:"go_version"`
+	GOOS        string    `json:"goos"`
+	GOARCH      string    `json:"goarch"`
+	GOMAXPROCS  int       `json:"gomaxprocs"`
+	NumCPU      int       `json:"num_cpu"`
+	CPUModel    string    `json:"cpu_model"`
+	CPUFlags    []string  `json:"cpu_flags"`
+	Kernel      string    `json:"kernel"`
+	Hostname    string    `json:"hostname"`
+	Timestamp   time.Time `json:"timestamp"`
+	GitRevision string    `json:"git_revision"`
+}
+
+// currentEnvironment is captured once per run so every result file written
+// by that run carries identical metadata.
+var currentEnvironment = sync.OnceValue(newEnvironment)
+
+func newEnvironment() Environment {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return Environment{
+		GoVersion:   runtime.Version(),
+		GOOS:        runtime.GOOS,
+		GOARCH:      runtime.GOARCH,
+		GOMAXPROCS:  runtime.GOMAXPROCS(0),
+		NumCPU:      runtime.NumCPU(),
+		CPUModel:    detectCPUModel(),
+		CPUFlags:    detectCPUFlags(),
+		Kernel:      detectKernel(),
+		Hostname:    hostname,
+		Timestamp:   time.Now().UTC(),
+		GitRevision: detectGitRevision(),
+	}
+}
+
+// fields lists the environment as ordered key/value pairs, used for the
+// benchstat configuration lines and the report.
+func (e Environment) fields() [][2]string {
+	return [][2]string{
+		{"go", e.GoVersion},
+		{"goos", e.GOOS},
+		{"goarch", e.GOARCH},
+		{"gomaxprocs", strconv.Itoa(e.GOMAXPROCS)},
+		{"numcpu", strconv.Itoa(e.NumCPU)},
+		{"cpu", e.CPUModel},
+		{"cpuflags", strings.Join(e.CPUFlags, ",")},
+		{"kernel", e.Kernel},
+		{"host", e.Hostname},
+		{"timestamp", e.Timestamp.Format(time.RFC3339)},
+		{"commit", e.GitRevision},
+	}
+}
+
+// summary is a one-line description used as the plot subtitle.
+func (e Environment) summary() string {
+	parts := []string{e.CPUModel, e.GOOS + "/" + e.GOARCH, e.GoVersion, e.Hostname}
+	if !e.Timestamp.IsZero() {
+		parts = append(parts, e.Timestamp.Format(time.DateOnly))
+	}
+	if e.GitRevision != "" {
+		parts = append(parts, e.GitRevision)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// environmentPath is the sidecar file next to a CSV result file that records
+// where it was measured, so that the CSV itself stays plain for pandas and R.
+func environmentPath(csvPath string) string {
+	return strings.TrimSuffix(csvPath, filepath.Ext(csvPath)) + ".env.json"
+}
+
+func writeEnvironment(csvPath string) error {
+	data, err := json.MarshalIndent(currentEnvironment(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(environmentPath(csvPath), append(data, '\n'), 0o644)
+}
+
+// readEnvironment returns nil without an error when the result file has no
+// sidecar.
+func readEnvironment(csvPath string) (*Environment, error) {
+	data, err := os.ReadFile(environmentPath(csvPath))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var env Environment
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", environmentPath(csvPath), err)
+	}
+	return &env, nil
+}
+
+func detectCPUModel() string {
+	switch runtime.GOOS {
+	case "linux":
+		if model := readCPUInfoField("model name"); model != "" {
+			return model
+		}
+		// arm64 kernels do not report "model name"
+		if model := readCPUInfoField("Model"); model != "" {
+			return model
+		}
+	case "darwin":
+		if out, err := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output(); err == nil {
+			return strings.TrimSpace(string(out))
+		}
+	}
+	return "unknown"
+}
+
+func detectCPUFlags() []string {
+	flags := []string{}
+	add := func(name string, present bool) {
+		if present {
+			flags = append(flags, name)
+		}
+	}
+
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		add("aes", cpu.X86.HasAES)
+		add("pclmulqdq", cpu.X86.HasPCLMULQDQ)
+		add("avx", cpu.X86.HasAVX)
+		add("avx2", cpu.X86.HasAVX2)
+		add("avx512f", cpu.X86.HasAVX512F)
+		add("vaes", cpu.X86.HasAVX512VAES)
+		add("bmi2", cpu.X86.HasBMI2)
+		add("adx", cpu.X86.HasADX)
+		// x/sys/cpu does not expose the SHA extensions on x86
+		add("sha_ni", slices.Contains(strings.Fields(readCPUInfoField("flags")), "sha_ni"))
+	case "arm64":
+		add("aes", cpu.ARM64.HasAES)
+		add("pmull", cpu.ARM64.HasPMULL)
+		add("sha1", cpu.ARM64.HasSHA1)
+		add("sha2", cpu.ARM64.HasSHA2)
+		add("sha3", cpu.ARM64.HasSHA3)
+		add("sha512", cpu.ARM64.HasSHA512)
+	}
+	return flags
+}
+
+func readCPUInfoField(name string) string {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if found && strings.TrimSpace(key) == name {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+func detectKernel() string {
+	if out, err := exec.Command("uname", "-sr").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return runtime.GOOS
+}
+
+func detectGitRevision() string {
+	if out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output(); err == nil {
+		revision := strings.TrimSpace(string(out))
+		if status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output(); err == nil && len(status) > 0 {
+			revision += "-dirty"
+		}
+		return revision
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/term"
+)
+
+// Encrypted file layout (version 2):
+//
+//	magic "LAB2ENC" | version (1 byte) | header length (uint32, big endian) |
+//	header (JSON) | sealed chunks
+//
+// The plaintext is split into chunks of the header's chunk size, sealed with
+// the STREAM construction (see newStreamAEAD); the last chunk is shorter,
+// possibly empty. Everything before the chunks is the HKDF salt of the file
+// key, so changing any of it makes the first chunk fail authentication.
+const (
+	fileMagic          = "LAB2ENC"
+	fileVersion        = 2
+	fileMaxHeaderSize  = 64 * 1024
+	fileMasterKeySize  = 32
+	fileSaltSize       = 16
+	fileChunkSize      = 64 * 1024
+	fileMaxChunkSize   = 16 * 1024 * 1024
+	filePasswordEnvVar = "LAB2_PASSWORD"
+
+	fileAlgAES128GCM = "AES-128-GCM"
+	fileAlgAES256GCM = "AES-256-GCM"
+	fileAlg3DESCBC   = "3DES-CBC-HMAC-SHA256"
+
+	fileKDFArgon2id = "argon2id"
+	fileKDFRSAOAEP  = "rsa-oaep-sha256"
+)
+
+type FileHeader struct {
+	Algorithm  string            `json:"alg"`
+	KDF        string            `json:"kdf"`
+	Argon2     *Argon2Params     `json:"argon2,omitempty"`
+	Salt       []byte            `json:"salt,omitempty"`
+	WrappedKey []byte            `json:"wrapped_key,omitempty"`
+	Nonce      []byte            `json:"nonce"`
+	ChunkSize  int               `json:"chunk"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+type Argon2Params struct {
+	Time      uint32 `json:"t"`
+	MemoryKiB uint32 `json:"m"`
+	Threads   uint8  `json:"p"`
+}
+
+type fileCipher struct {
+	keySize int
+	newAEAD func(key []byte) (cipher.AEAD, error)
+}
+
+var fileCiphers = map[string]fileCipher{
+	fileAlgAES128GCM: {16, newFileGCM},
+	fileAlgAES256GCM: {32, newFileGCM},
+	fileAlg3DESCBC:   {24 + 32, newFile3DESCBC},
+}
+
+func runEncryptFile(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	in := fs.String("in", "", "file to encrypt")
+	out := fs.String("out", "", "output file (default <in>.enc)")
+	alg := fs.String("alg", fileAlgAES256GCM, "one of "+strings.Join(fileAlgorithmNames(), ", "))
+	recipient := fs.String("recipient", "", "encrypt to this RSA public key (PEM) instead of a password")
+	passwordFile := fs.String("password-file", "", "read the password from this file (default $"+filePasswordEnvVar+" or the terminal)")
+	comment := fs.String("comment", "", "free-form comment stored in the authenticated metadata")
+	argonTime := fs.Uint("argon-time", 3, "Argon2id passes (at least 1)")
+	argonMemory := fs.Uint("argon-memory", 64*1024, "Argon2id memory in KiB (at least 8 per thread)")
+	argonThreads := fs.Uint("argon-threads", 4, "Argon2id threads (1 to 255)")
+	force := fs.Bool("force", false, "overwrite the output file if it exists")
+	fs.Parse(args)
+
+	if *in == "" {
+		log.Fatalf("encrypt: -in is required")
+	}
+	if *out == "" {
+		*out = *in + ".enc"
+	}
+	if err := checkFileOutput(*in, *out, *force); err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+	algorithm, c, err := lookupFileCipher(*alg)
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+
+	header := &FileHeader{
+		Algorithm: algorithm,
+		ChunkSize: fileChunkSize,
+		Metadata: map[string]string{
+			"filename": filepath.Base(*in),
+			"size":     strconv.FormatInt(info.Size(), 10),
+			"created":  time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	if *comment != "" {
+		header.Metadata["comment"] = *comment
+	}
+
+	var masterKey []byte
+	if *recipient != "" {
+		publicKey, err := loadRSAPublicKey(*recipient)
+		if err != nil {
+			log.Fatalf("encrypt: %v", err)
+		}
+		masterKey = randomBytes(fileMasterKeySize)
+		header.KDF = fileKDFRSAOAEP
+		header.WrappedKey, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, masterKey, []byte(fileMagic))
+		if err != nil {
+			log.Fatalf("encrypt: wrapping file key: %v", err)
+		}
+	} else {
+		// checked before the conversions below, which would silently truncate
+		switch {
+		case *argonTime < 1 || *argonTime > math.MaxUint32:
+			log.Fatalf("encrypt: -argon-time must be between 1 and %d", uint32(math.MaxUint32))
+		case *argonThreads < 1 || *argonThreads > math.MaxUint8:
+			log.Fatalf("encrypt: -argon-threads must be between 1 and %d", math.MaxUint8)
+		case *argonMemory < 8**argonThreads:
+			log.Fatalf("encrypt: -argon-memory must be at least 8 KiB per thread (%d KiB)", 8**argonThreads)
+		case *argonMemory > math.MaxUint32:
+			log.Fatalf("encrypt: -argon-memory must be at most %d KiB", uint32(math.MaxUint32))
+		}
+		header.KDF = fileKDFArgon2id
+		header.Argon2 = &Argon2Params{uint32(*argonTime), uint32(*argonMemory), uint8(*argonThreads)}
+		if err := header.Argon2.validate(); err != nil {
+			log.Fatalf("encrypt: %v", err)
+		}
+		password, err := readPassword(*passwordFile, true)
+		if err != nil {
+			log.Fatalf("encrypt: %v", err)
+		}
+		header.Salt = randomBytes(fileSaltSize)
+		masterKey = deriveArgon2Key(password, header.Salt, header.Argon2)
+	}
+
+	aead, err := c.newAEAD(make([]byte, c.keySize))
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+	header.Nonce = randomBytes(aead.NonceSize() - 5)
+
+	prefix, err := marshalFileHeader(header)
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+	encryptChunk, _, err := newFileStream(c, algorithm, masterKey, header, prefix)
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+
+	err = writeFileOutput(*out, func(w io.Writer) error {
+		if _, err := w.Write(prefix); err != nil {
+			return err
+		}
+		return transformFileChunks(src, w, header.ChunkSize, encryptChunk)
+	})
+	if err != nil {
+		log.Fatalf("encrypt: %v", err)
+	}
+	fmt.Printf("Encrypted %s -> %s (%s, %s)\n", *in, *out, algorithm, header.KDF)
+}
+
+func runDecryptFile(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	in := fs.String("in", "", "file to decrypt")
+	out := fs.String("out", "", "output file (default <in> without .enc)")
+	keyFile := fs.String("key", "", "RSA private key (PEM) for files encrypted to a recipient")
+	passwordFile := fs.String("password-file", "", "read the password from this file (default $"+filePasswordEnvVar+" or the terminal)")
+	force := fs.Bool("force", false, "overwrite the output file if it exists")
+	fs.Parse(args)
+
+	if *in == "" {
+		log.Fatalf("decrypt: -in is required")
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(*in, ".enc")
+		if *out == *in {
+			*out = *in + ".dec"
+		}
+	}
+	if err := checkFileOutput(*in, *out, *force); err != nil {
+		log.Fatalf("decrypt: %v", err)
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("decrypt: %v", err)
+	}
+	defer src.Close()
+	r := bufio.NewReader(src)
+
+	header, prefix, err := readFileHeader(r)
+	if err != nil {
+		log.Fatalf("decrypt: %v", err)
+	}
+	algorithm, c, err := lookupFileCipher(header.Algorithm)
+	if err != nil {
+		log.Fatalf("decrypt: %v", err)
+	}
+	if header.ChunkSize <= 0 || header.ChunkSize > fileMaxChunkSize {
+		log.Fatalf("decrypt: invalid chunk size %d", header.ChunkSize)
+	}
+
+	var masterKey []byte
+	switch header.KDF {
+	case fileKDFRSAOAEP:
+		if *keyFile == "" {
+			log.Fatalf("decrypt: file is encrypted to an RSA key, use -key")
+		}
+		privateKey, err := loadRSAPrivateKey(*keyFile)
+		if err != nil {
+			log.Fatalf("decrypt: %v", err)
+		}
+		masterKey, err = rsa.DecryptOAEP(sha256.New(), nil, privateKey, header.WrappedKey, []byte(fileMagic))
+		if err != nil {
+			log.Fatalf("decrypt: unwrapping file key: %v", err)
+		}
+	case fileKDFArgon2id:
+		if err := header.Argon2.validate(); err != nil {
+			log.Fatalf("decrypt: %v", err)
+		}
+		password, err := readPassword(*passwordFile, false)
+		if err != nil {
+			log.Fatalf("decrypt: %v", err)
+		}
+		masterKey = deriveArgon2Key(password, header.Salt, header.Argon2)
+	default:
+		log.Fatalf("decrypt: unsupported key derivation %q", header.KDF)
+	}
+
+	_, decryptChunk, err := newFileStream(c, algorithm, masterKey, header, prefix)
+	if err != nil {
+		log.Fatalf("decrypt: %v", err)
+	}
+
+	// the plaintext is only moved to the output once every chunk has been
+	// authenticated, so a modified file never leaves partial output behind
+	err = writeFileOutput(*out, func(w io.Writer) error {
+		return transformFileChunks(r, w, header.ChunkSize+sealedChunkOverhead(c), decryptChunk)
+	})
+	if err != nil {
+		log.Fatalf("decrypt: wrong password or key, or the file was modified: %v", err)
+	}
+
+	fmt.Printf("Decrypted %s -> %s (%s, %s)\n", *in, *out, algorithm, header.KDF)
+	for _, name := range []string{"filename", "size", "created", "comment"} {
+		if value, ok := header.Metadata[name]; ok {
+			fmt.Printf("  %s: %s\n", name, value)
+		}
+	}
+}
+
+// checkFileOutput refuses to write over the input, and over any other
+// existing file unless forced.
+func checkFileOutput(in, out string, force bool) error {
+	outInfo, err := os.Stat(out)
+	if err != nil {
+		return nil
+	}
+	if inInfo, err := os.Stat(in); err == nil && os.SameFile(inInfo, outInfo) {
+		return fmt.Errorf("%s is both the input and the output", out)
+	}
+	if !force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", out)
+	}
+	return nil
+}
+
+// writeFileOutput writes to a temporary file next to filename and renames it
+// over filename only when write succeeds.
+func writeFileOutput(filename string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// newFileStream derives the file key, with the header bytes as HKDF salt, and
+// returns the STREAM transforms for it.
+func newFileStream(c fileCipher, algorithm string, masterKey []byte, header *FileHeader, prefix []byte) (ChunkFunc, ChunkFunc, error) {
+	key, err := hkdf.Key(sha256.New, masterKey, prefix, "lab2 file "+algorithm, c.keySize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("deriving key: %w", err)
+	}
+	aead, err := c.newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(header.Nonce) != aead.NonceSize()-5 {
+		return nil, nil, fmt.Errorf("invalid nonce prefix length %d", len(header.Nonce))
+	}
+	encryptChunk, decryptChunk := newStreamAEAD(aead, header.Nonce)
+	return encryptChunk, decryptChunk, nil
+}
+
+// sealedChunkOverhead is what sealing adds to a full chunk. Full chunks are a
+// whole number of blocks, so it is the same for all of them.
+func sealedChunkOverhead(c fileCipher) int {
+	aead, err := c.newAEAD(make([]byte, c.keySize))
+	if err != nil {
+		log.Fatalf("Error creating file cipher: %v", err)
+	}
+	return aead.Overhead()
+}
+
+// transformFileChunks reads src in chunks of chunkSize and writes every
+// transformed chunk to dst. A short chunk is the last one; the encrypted
+// stream always ends with one, which is empty for plaintexts that are a
+// whole number of chunks.
+func transformFileChunks(src io.Reader, dst io.Writer, chunkSize int, transform ChunkFunc) error {
+	chunk := make([]byte, chunkSize)
+	var buf []byte
+	for last := false; !last; {
+		n, err := io.ReadFull(src, chunk)
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			last = true
+		case err != nil:
+			return err
+		}
+
+		buf, err = transform(buf[:0], chunk[:n], last)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runKeyGenFile(args []string) {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	bits := fs.Int("bits", 3072, "RSA key size")
+	out := fs.String("out", "lab2", "writes <out>.key (private) and <out>.pub (public)")
+	fs.Parse(args)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, *bits)
+	if err != nil {
+		log.Fatalf("keygen: %v", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		log.Fatalf("keygen: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		log.Fatalf("keygen: %v", err)
+	}
+
+	if err := os.WriteFile(*out+".key", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600); err != nil {
+		log.Fatalf("keygen: %v", err)
+	}
+	if err := os.WriteFile(*out+".pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644); err != nil {
+		log.Fatalf("keygen: %v", err)
+	}
+	fmt.Printf("Wrote RSA-%d key pair to %s.key and %s.pub\n", *bits, *out, *out)
+}
+
+func fileAlgorithmNames() []string {
+	return []string{fileAlgAES256GCM, fileAlgAES128GCM, fileAlg3DESCBC}
+}
+
+func lookupFileCipher(name string) (string, fileCipher, error) {
+	for _, algorithm := range fileAlgorithmNames() {
+		if strings.EqualFold(name, algorithm) {
+			return algorithm, fileCiphers[algorithm], nil
+		}
+	}
+	return "", fileCipher{}, fmt.Errorf("unsupported algorithm %q", name)
+}
+
+func marshalFileHeader(header *FileHeader) ([]byte, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if len(headerJSON) > fileMaxHeaderSize {
+		return nil, fmt.Errorf("header too large")
+	}
+
+	prefix := append([]byte(fileMagic), fileVersion)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(headerJSON)))
+	return append(prefix, headerJSON...), nil
+}
+
+// readFileHeader returns the decoded header and the raw bytes preceding the
+// chunks, leaving r at the first chunk.
+func readFileHeader(r io.Reader) (*FileHeader, []byte, error) {
+	fixedSize := len(fileMagic) + 1 + 4
+	prefix := make([]byte, fixedSize)
+	if _, err := io.ReadFull(r, prefix); err != nil || string(prefix[:len(fileMagic)]) != fileMagic {
+		return nil, nil, errors.New("not a lab2 encrypted file")
+	}
+	if version := prefix[len(fileMagic)]; version != fileVersion {
+		return nil, nil, fmt.Errorf("unsupported file version %d", version)
+	}
+
+	headerSize := binary.BigEndian.Uint32(prefix[len(fileMagic)+1:])
+	if headerSize > fileMaxHeaderSize {
+		return nil, nil, errors.New("corrupt header")
+	}
+	prefix = append(prefix, make([]byte, headerSize)...)
+	if _, err := io.ReadFull(r, prefix[fixedSize:]); err != nil {
+		return nil, nil, errors.New("truncated header")
+	}
+
+	header := &FileHeader{}
+	if err := json.Unmarshal(prefix[fixedSize:], header); err != nil {
+		return nil, nil, fmt.Errorf("corrupt header: %w", err)
+	}
+	return header, prefix, nil
+}
+
+func (p *Argon2Params) validate() error {
+	switch {
+	case p == nil:
+		return errors.New("missing Argon2id parameters")
+	case p.Time == 0 || p.Threads == 0 || p.MemoryKiB < 8*uint32(p.Threads):
+		return errors.New("invalid Argon2id parameters")
+	case p.MemoryKiB > 4*1024*1024:
+		return fmt.Errorf("refusing Argon2id memory of %d KiB", p.MemoryKiB)
+	}
+	return nil
+}
+
+func deriveArgon2Key(password, salt []byte, p *Argon2Params) []byte {
+	return argon2.IDKey(password, salt, p.Time, p.MemoryKiB, p.Threads, fileMasterKeySize)
+}
+
+// readPassword takes the password from a file, the environment or the
+// terminal without echoing it, asking twice there when confirm is set. When
+// stdin is not a terminal a line is read from it instead.
+func readPassword(passwordFile string, confirm bool) ([]byte, error) {
+	var password []byte
+	if passwordFile != "" {
+		contents, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return nil, err
+		}
+		password = bytes.TrimRight(contents, "\r\n")
+	} else if value, ok := os.LookupEnv(filePasswordEnvVar); ok {
+		password = []byte(value)
+	} else if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		var err error
+		password, err = promptPassword(fd, "Password: ")
+		if err != nil {
+			return nil, err
+		}
+		if confirm && len(password) > 0 {
+			again, err := promptPassword(fd, "Repeat password: ")
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(password, again) {
+				return nil, errors.New("passwords do not match")
+			}
+		}
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("reading password: %w", err)
+		}
+		password = []byte(strings.TrimRight(line, "\r\n"))
+	}
+
+	if len(password) == 0 {
+		return nil, errors.New("empty password")
+	}
+	return password, nil
+}
+
+func promptPassword(fd int, prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("reading password: %w", err)
+	}
+	return password, nil
+}
+
+func loadRSAPublicKey(filename string) (*rsa.PublicKey, error) {
+	block, err := readPEMBlock(filename, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA public key", filename)
+	}
+	return publicKey, nil
+}
+
+func loadRSAPrivateKey(filename string) (*rsa.PrivateKey, error) {
+	block, err := readPEMBlock(filename, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA private key", filename)
+	}
+	return privateKey, nil
+}
+
+func readPEMBlock(filename, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a %s PEM block", filename, blockType)
+	}
+	return block, nil
+}
+
+func newFileGCM(key []byte) (cipher.AEAD, error) {
+	return newAESGCM(key), nil
+}
+
+// fileCBCHMAC is 3DES-CBC with encrypt-then-MAC as a cipher.AEAD, so that it
+// can be used with newStreamAEAD: the first 24 key bytes are the 3DES key,
+// the remaining 32 the HMAC-SHA256 key. The IV is derived from the nonce with
+// the MAC key and the tag covers aad, the nonce and the ciphertext.
+type fileCBCHMAC struct {
+	block  cipher.Block
+	macKey []byte
+}
+
+func newFile3DESCBC(key []byte) (cipher.AEAD, error) {
+	block, err := des.NewTripleDESCipher(key[:24])
+	if err != nil {
+		return nil, err
+	}
+	return &fileCBCHMAC{block, key[24:]}, nil
+}
+
+func (c *fileCBCHMAC) NonceSize() int { return 12 }
+
+func (c *fileCBCHMAC) Overhead() int { return des.BlockSize + sha256.Size }
+
+func (c *fileCBCHMAC) Seal(dst, nonce, plaintext, aad []byte) []byte {
+	ciphertext := pkcs7Pad(bytes.Clone(plaintext), des.BlockSize)
+	cipher.NewCBCEncrypter(c.block, c.iv(nonce)).CryptBlocks(ciphertext, ciphertext)
+	dst = append(dst, ciphertext...)
+	return append(dst, c.tag(aad, nonce, ciphertext)...)
+}
+
+func (c *fileCBCHMAC) Open(dst, nonce, data, aad []byte) ([]byte, error) {
+	if len(data) < sha256.Size+des.BlockSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	ciphertext, tag := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	// the tag is checked before decrypting, so pkcs7Unpad never acts as a
+	// padding oracle on forged ciphertexts
+	if !hmac.Equal(tag, c.tag(aad, nonce, ciphertext)) {
+		return nil, errors.New("message authentication failed")
+	}
+	if len(ciphertext)%des.BlockSize != 0 {
+		return nil, errors.New("ciphertext is not a whole number of blocks")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(c.block, c.iv(nonce)).CryptBlocks(plaintext, ciphertext)
+	plaintext, err := pkcs7Unpad(plaintext, des.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, plaintext...), nil
+}
+
+func (c *fileCBCHMAC) iv(nonce []byte) []byte {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write([]byte{0})
+	mac.Write(nonce)
+	return mac.Sum(nil)[:des.BlockSize]
+}
+
+func (c *fileCBCHMAC) tag(aad, nonce, ciphertext []byte) []byte {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write([]byte{1})
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(len(aad))))
+	mac.Write(aad)
+	mac.Write(nonce)
+	mac.Write(ciphertext)
+	return mac.Sum(nil)
+}
@@ -2,7 +2,14 @@ module lab2
 
 go 1.25.1
 
-require gonum.org/v1/plot v0.16.0
+require (
+	github.com/cloudflare/circl v1.6.5
+	github.com/magical/go-ascon v0.0.0-20250814060253-762693554ab4
+	golang.org/x/crypto v0.55.0
+	golang.org/x/sys v0.47.0
+	golang.org/x/term v0.45.0
+	gonum.org/v1/plot v0.16.0
+)
 
 require (
 	codeberg.org/go-fonts/liberation v0.5.0 // indirect
@@ -14,6 +21,6 @@ require (
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/image v0.25.0 // indirect
-	golang.org/x/text v0.23.0 // indirect
+	golang.org/x/text v0.41.0 // indirect
 	rsc.io/pdf v0.1.1 // indirect
 )
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"log"
+)
+
+const hybridInfo = "lab2 hybrid encryption"
+
+func setupEncryptHybridRSA(bits int) (EncryptFunc, func()) {
+	privateKey := benchmarkRSAKey(bits)
+	publicKey := &privateKey.PublicKey
+
+	return func(data []byte) (func(), func()) {
+		encrypt := func() ([]byte, []byte, []byte) {
+			dataKey := make([]byte, 32)
+			if _, err := rand.Read(dataKey); err != nil {
+				log.Fatalf("Error generating AES key: %v", err)
+			}
+			wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, dataKey, nil)
+			if err != nil {
+				log.Fatalf("Error wrapping AES key: %v", err)
+			}
+			nonce, ciphertext := sealAESGCM(dataKey, data)
+			return wrappedKey, nonce, ciphertext
+		}
+		wrappedKey, nonce, ciphertext := encrypt()
+
+		return func() { encrypt() }, func() {
+			unwrappedKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, wrappedKey, nil)
+			if err != nil {
+				log.Fatalf("Error unwrapping AES key: %v", err)
+			}
+			openAESGCM(unwrappedKey, nonce, ciphertext)
+		}
+	}, func() {}
+}
+
+func setupEncryptHybridX25519() (EncryptFunc, func()) {
+	privateKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Error generating X25519 key: %v", err)
+	}
+	publicKey := privateKey.PublicKey()
+
+	return func(data []byte) (func(), func()) {
+		encrypt := func() ([]byte, []byte, []byte) {
+			ephemeralKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+			if err != nil {
+				log.Fatalf("Error generating ephemeral X25519 key: %v", err)
+			}
+			sharedSecret, err := ephemeralKey.ECDH(publicKey)
+			if err != nil {
+				log.Fatalf("Error computing X25519 shared secret: %v", err)
+			}
+			ephemeralPublic := ephemeralKey.PublicKey().Bytes()
+			dataKey := deriveHybridKey(sharedSecret, ephemeralPublic)
+			nonce, ciphertext := sealAESGCM(dataKey, data)
+			return ephemeralPublic, nonce, ciphertext
+		}
+		ephemeralPublic, nonce, ciphertext := encrypt()
+
+		return func() { encrypt() }, func() {
+			peerKey, err := ecdh.X25519().NewPublicKey(ephemeralPublic)
+			if err != nil {
+				log.Fatalf("Error parsing ephemeral X25519 key: %v", err)
+			}
+			sharedSecret, err := privateKey.ECDH(peerKey)
+			if err != nil {
+				log.Fatalf("Error computing X25519 shared secret: %v", err)
+			}
+			openAESGCM(deriveHybridKey(sharedSecret, ephemeralPublic), nonce, ciphertext)
+		}
+	}, func() {}
+}
+
+func setupEncryptHybridMLKEM768() (EncryptFunc, func()) {
+	decapsulationKey, err := mlkem.GenerateKey768()
+	if err != nil {
+		log.Fatalf("Error generating ML-KEM-768 key: %v", err)
+	}
+	encapsulationKey := decapsulationKey.EncapsulationKey()
+
+	return func(data []byte) (func(), func()) {
+		encrypt := func() ([]byte, []byte, []byte) {
+			sharedKey, kemCiphertext := encapsulationKey.Encapsulate()
+			nonce, ciphertext := sealAESGCM(sharedKey, data)
+			return kemCiphertext, nonce, ciphertext
+		}
+		kemCiphertext, nonce, ciphertext := encrypt()
+
+		return func() { encrypt() }, func() {
+			sharedKey, err := decapsulationKey.Decapsulate(kemCiphertext)
+			if err != nil {
+				log.Fatalf("Error decapsulating ML-KEM-768 key: %v", err)
+			}
+			openAESGCM(sharedKey, nonce, ciphertext)
+		}
+	}, func() {}
+}
+
+func deriveHybridKey(sharedSecret, ephemeralPublic []byte) []byte {
+	key, err := hkdf.Key(sha256.New, sharedSecret, ephemeralPublic, hybridInfo, 32)
+	if err != nil {
+		log.Fatalf("Error deriving key with HKDF: %v", err)
+	}
+	return key
+}
+
+func sealAESGCM(key, data []byte) ([]byte, []byte) {
+	gcm := newAESGCM(key)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		log.Fatalf("Error generating nonce: %v", err)
+	}
+
+	return nonce, gcm.Seal(nil, nonce, data, nil)
+}
+
+func openAESGCM(key, nonce, ciphertext []byte) []byte {
+	plaintext, err := newAESGCM(key).Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		log.Fatalf("Error decrypting AES-GCM: %v", err)
+	}
+	return plaintext
+}
+
+func newAESGCM(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatalf("Error creating AES cipher: %v", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		log.Fatalf("Error creating GCM: %v", err)
+	}
+	return gcm
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	kdfPassword  = "correct horse battery staple"
+	kdfKeyLength = 32
+)
+
+type KDFResult struct {
+	param          int
+	allocatedBytes uint64
+	result         *BenchmarkResult
+}
+
+type KDFSweep struct {
+	name       string
+	paramName  string
+	filepath   string
+	params     []int
+	iterations int
+	newKDF     func(param int) func() error
+}
+
+func runKDFBenchmarks(args []string) {
+	fs := flag.NewFlagSet("kdf", flag.ExitOnError)
+	plotFormatFlag(fs)
+	fs.BoolVar(&exportRawSamples, "raw", false, "also write every sample to results/kdf/raw/")
+	fs.BoolVar(&captureProfiles, "pprof", false, "write CPU and heap profiles per sweep point to results/kdf/pprof/ (slows down measurement)")
+	fs.Parse(args)
+
+	targetLatency := time.Duration(0)
+	if fs.NArg() > 0 {
+		ms, err := strconv.ParseFloat(fs.Arg(0), 64)
+		if err != nil {
+			log.Fatalf("invalid target latency %q (expected milliseconds): %v", fs.Arg(0), err)
+		}
+		targetLatency = time.Duration(ms * float64(time.Millisecond))
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		log.Fatalf("Error generating salt: %v", err)
+	}
+
+	sweeps := []KDFSweep{
+		{
+			name: "PBKDF2-SHA256", paramName: "iterations", filepath: "results/kdf/pbkdf2_iterations.csv",
+			params: []int{1_000, 10_000, 100_000, 300_000, 600_000, 1_000_000}, iterations: 10,
+			newKDF: func(iter int) func() error {
+				return func() error {
+					_, err := pbkdf2.Key(sha256.New, kdfPassword, salt, iter, kdfKeyLength)
+					return err
+				}
+			},
+		},
+		{
+			name: "scrypt (r=8, p=1)", paramName: "N", filepath: "results/kdf/scrypt_n.csv",
+			params: []int{1 << 12, 1 << 13, 1 << 14, 1 << 15, 1 << 16, 1 << 17}, iterations: 10,
+			newKDF: func(n int) func() error { return newScrypt(salt, n, 8, 1) },
+		},
+		{
+			name: "scrypt (N=2^15, p=1)", paramName: "r", filepath: "results/kdf/scrypt_r.csv",
+			params: []int{1, 2, 4, 8, 16}, iterations: 10,
+			newKDF: func(r int) func() error { return newScrypt(salt, 1<<15, r, 1) },
+		},
+		{
+			name: "scrypt (N=2^15, r=8)", paramName: "p", filepath: "results/kdf/scrypt_p.csv",
+			params: []int{1, 2, 4, 8}, iterations: 10,
+			newKDF: func(p int) func() error { return newScrypt(salt, 1<<15, 8, p) },
+		},
+		{
+			name: "Argon2id (m=64 MiB, p=1)", paramName: "time", filepath: "results/kdf/argon2id_time.csv",
+			params: []int{1, 2, 3, 4, 6, 8}, iterations: 10,
+			newKDF: func(t int) func() error { return newArgon2id(salt, t, 64*1024, 1) },
+		},
+		{
+			name: "Argon2id (t=1, p=1)", paramName: "memory (KiB)", filepath: "results/kdf/argon2id_memory.csv",
+			params: []int{16 * 1024, 32 * 1024, 64 * 1024, 128 * 1024, 256 * 1024}, iterations: 10,
+			newKDF: func(m int) func() error { return newArgon2id(salt, 1, m, 1) },
+		},
+		{
+			name: "Argon2id (t=1, m=64 MiB)", paramName: "threads", filepath: "results/kdf/argon2id_threads.csv",
+			params: []int{1, 2, 4, 8}, iterations: 10,
+			newKDF: func(p int) func() error { return newArgon2id(salt, 1, 64*1024, p) },
+		},
+		{
+			name: "HKDF-SHA256", paramName: "output bytes", filepath: "results/kdf/hkdf_length.csv",
+			params: []int{16, 32, 64, 256, 1024, 8160}, iterations: 1000,
+			newKDF: func(length int) func() error {
+				return func() error {
+					_, err := hkdf.Key(sha256.New, []byte(kdfPassword), salt, "lab2 kdf", length)
+					return err
+				}
+			},
+		},
+	}
+
+	doc := newResultsDocument()
+
+	fmt.Println("=== KEY DERIVATION BENCHMARKS ===")
+	for _, sweep := range sweeps {
+		results := runKDFSweep(sweep)
+		exportKDF(results, sweep.filepath)
+		doc.addKDFResults(sweep.name, sweep.paramName, results)
+
+		if targetLatency > 0 {
+			printKDFRecommendation(sweep, results, targetLatency)
+		}
+	}
+
+	exportJSON("results/kdf/results.json", doc)
+	exportBenchstat("results/kdf/benchstat.txt", doc)
+}
+
+func runKDFSweep(sweep KDFSweep) []*KDFResult {
+	results := make([]*KDFResult, 0, len(sweep.params))
+	for _, param := range sweep.params {
+		fmt.Printf("Calculating for %s; %s=%d\n", sweep.name, sweep.paramName, param)
+		stopProfile := startProfile(profilePrefix("results/kdf", sweep.name+" "+sweep.paramName), param)
+		durations, allocatedBytes, memory := measureKDF(sweep.iterations, sweep.newKDF(param))
+		stopProfile()
+
+		result := calculateBenchmarkResult(durations)
+		result.memory = memory
+		results = append(results, &KDFResult{param, allocatedBytes, result})
+	}
+	return results
+}
+
+// measureKDF times each derivation and records the largest number of bytes
+// allocated by a single one. For scrypt and Argon2 that is dominated by the
+// memory-hard buffer; it is a TotalAlloc delta, not a heap high-water mark.
+func measureKDF(iterations int, kdfFunc func() error) ([]time.Duration, uint64, MemoryStats) {
+	// warm up
+	if err := kdfFunc(); err != nil {
+		log.Fatalf("Error during warm-up key derivation: %v\n", err)
+	}
+
+	durations := make([]time.Duration, 0, iterations)
+	allocatedBytes := uint64(0)
+	memory := MemoryStats{}
+
+	var before, after runtime.MemStats
+	for range iterations {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		start := time.Now()
+		if err := kdfFunc(); err != nil {
+			log.Fatalf("Error during key derivation: %v\n", err)
+		}
+		durations = append(durations, time.Since(start))
+
+		runtime.ReadMemStats(&after)
+		allocatedBytes = max(allocatedBytes, after.TotalAlloc-before.TotalAlloc)
+		// forced collections between iterations are excluded
+		memory = memory.plus(newMemoryStats(&before, &after, iterations))
+	}
+
+	return durations, allocatedBytes, memory
+}
+
+func newScrypt(salt []byte, n, r, p int) func() error {
+	return func() error {
+		_, err := scrypt.Key([]byte(kdfPassword), salt, n, r, p, kdfKeyLength)
+		return err
+	}
+}
+
+func newArgon2id(salt []byte, timeCost, memoryKiB, threads int) func() error {
+	return func() error {
+		argon2.IDKey([]byte(kdfPassword), salt, uint32(timeCost), uint32(memoryKiB), uint8(threads), kdfKeyLength)
+		return nil
+	}
+}
+
+func exportKDF(results []*KDFResult, filepath string) {
+	header := append([]string{"Parameter"}, benchmarkResultHeader("")...)
+	header = append(header, "Allocated bytes")
+	records := [][]string{append(header, memoryStatsHeader("")...)}
+	for _, result := range results {
+		row := append([]string{fmt.Sprint(result.param)}, benchmarkResultColumns(result.result)...)
+		row = append(row, fmt.Sprint(result.allocatedBytes))
+		records = append(records, append(row, memoryStatsColumns(result.result.memory)...))
+	}
+	exportToCSV(filepath, records)
+
+	if exportRawSamples {
+		raw := [][]string{rawSamplesHeader("Parameter")}
+		for _, result := range results {
+			raw = appendRawSamples(raw, fmt.Sprint(result.param), "warm", result.result)
+		}
+		exportToCSV(rawSamplesPath(filepath), raw)
+	}
+}
+
+func printKDFRecommendation(sweep KDFSweep, results []*KDFResult, targetLatency time.Duration) {
+	var best *KDFResult
+	for _, result := range results {
+		if result.result.percentile95 <= targetLatency {
+			best = result
+		}
+	}
+
+	if best == nil {
+		fmt.Printf("  %s: no %s value stays under %v (p95)\n", sweep.name, sweep.paramName, targetLatency)
+		return
+	}
+	fmt.Printf("  %s: %s=%d stays under %v (median %v, p95 %v)\n",
+		sweep.name, sweep.paramName, best.param, targetLatency, best.result.median, best.result.percentile95)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	keyFormatPEM = "pem"
+	keyFormatDER = "der"
+	keyFormatJWK = "jwk"
+)
+
+// KeyStore writes every generated key to dir. RSA keys use the configured
+// format and are accompanied by their public key (PKIX for PEM/DER) under
+// <kind>-public; symmetric keys have no standard PEM/DER encoding and are
+// always written as JWK.
+type KeyStore struct {
+	dir      string
+	format   string
+	counters map[string]int
+}
+
+// JWK is the subset of RFC 7517/7518 needed for RSA and symmetric keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	DP  string `json:"dp,omitempty"`
+	DQ  string `json:"dq,omitempty"`
+	QI  string `json:"qi,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+type KeyFormatResult struct {
+	key       string
+	format    string
+	bytes     int
+	serialize *BenchmarkResult
+	parse     *BenchmarkResult
+}
+
+var (
+	// keyStore is set by -save-keys; nil means generated keys are dropped.
+	keyStore *KeyStore
+	// loadedKeys holds the keys read with -load-keys, by kind ("rsa2048",
+	// "aes256", ...), which the encryption setups use instead of fresh ones.
+	loadedKeys = map[string]any{}
+)
+
+func newKeyStore(dir, format string) (*KeyStore, error) {
+	if !slices.Contains([]string{keyFormatPEM, keyFormatDER, keyFormatJWK}, format) {
+		return nil, fmt.Errorf("unknown key format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &KeyStore{dir: dir, format: format, counters: map[string]int{}}, nil
+}
+
+func (s *KeyStore) save(kind string, key any) error {
+	s.counters[kind]++
+	n := s.counters[kind]
+	if err := s.write(kind, n, key); err != nil {
+		return err
+	}
+	if privateKey, ok := key.(*rsa.PrivateKey); ok {
+		return s.write(kind+"-public", n, &privateKey.PublicKey)
+	}
+	return nil
+}
+
+func (s *KeyStore) write(kind string, n int, key any) error {
+	format := s.format
+	if _, ok := key.([]byte); ok {
+		format = keyFormatJWK
+	}
+
+	data, err := encodeKey(key, format)
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(s.dir, fmt.Sprintf("%s_%04d.%s", kind, n, format))
+	return os.WriteFile(filename, data, 0o600)
+}
+
+// loadKeyDir reads keys named <kind>[_<n>].<format>; the first key of every
+// kind in lexical order wins.
+func loadKeyDir(dir string) (map[string]any, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]any{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		format := strings.TrimPrefix(filepath.Ext(name), ".")
+		kind, _, _ := strings.Cut(strings.TrimSuffix(name, filepath.Ext(name)), "_")
+		if _, ok := keys[kind]; ok {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		key, err := decodeKey(data, format)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		keys[kind] = key
+	}
+	return keys, nil
+}
+
+// benchmarkRSAKey returns the loaded key of that size or a fresh one. A
+// loaded key that is not an RSA private key of that size is fatal rather
+// than silently replaced.
+func benchmarkRSAKey(bits int) *rsa.PrivateKey {
+	kind := fmt.Sprintf("rsa%d", bits)
+	if loaded, ok := loadedKeys[kind]; ok {
+		key, ok := loaded.(*rsa.PrivateKey)
+		if !ok {
+			log.Fatalf("Loaded %s key is a %T, expected an RSA private key", kind, loaded)
+		}
+		if key.N.BitLen() != bits {
+			log.Fatalf("Loaded %s key has %d bits", kind, key.N.BitLen())
+		}
+		return key
+	}
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		log.Fatalf("Error generating RSA key: %v", err)
+	}
+	return privateKey
+}
+
+// benchmarkSymmetricKey returns a copy of the loaded key, since setups clear
+// their key on teardown, or a fresh random one. Like benchmarkRSAKey it
+// refuses a loaded key of the wrong type or size.
+func benchmarkSymmetricKey(kind string, size int) []byte {
+	if loaded, ok := loadedKeys[kind]; ok {
+		key, ok := loaded.([]byte)
+		if !ok {
+			log.Fatalf("Loaded %s key is a %T, expected a symmetric key", kind, loaded)
+		}
+		if len(key) != size {
+			log.Fatalf("Loaded %s key has %d bytes, expected %d", kind, len(key), size)
+		}
+		return slices.Clone(key)
+	}
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Error generating %s key: %v", kind, err)
+	}
+	return key
+}
+
+func encodeKey(key any, format string) ([]byte, error) {
+	switch format {
+	case keyFormatJWK:
+		return marshalJWK(key)
+	case keyFormatDER, keyFormatPEM:
+		var der []byte
+		var blockType string
+		var err error
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			der, err = x509.MarshalPKCS8PrivateKey(k)
+			blockType = "PRIVATE KEY"
+		case *rsa.PublicKey:
+			der, err = x509.MarshalPKIXPublicKey(k)
+			blockType = "PUBLIC KEY"
+		default:
+			return nil, fmt.Errorf("%T has no %s encoding", key, format)
+		}
+		if err != nil || format == keyFormatDER {
+			return der, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), nil
+	}
+	return nil, fmt.Errorf("unknown key format %q", format)
+}
+
+func decodeKey(data []byte, format string) (any, error) {
+	switch format {
+	case keyFormatJWK:
+		return parseJWK(data)
+	case keyFormatPEM:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM block found")
+		}
+		data = block.Bytes
+		fallthrough
+	case keyFormatDER:
+		if key, err := x509.ParsePKCS8PrivateKey(data); err == nil {
+			return key, nil
+		}
+		return x509.ParsePKIXPublicKey(data)
+	}
+	return nil, fmt.Errorf("unknown key format %q", format)
+}
+
+func marshalJWK(key any) ([]byte, error) {
+	var jwk JWK
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA keys are not supported")
+		}
+		k.Precompute()
+		jwk = JWK{
+			Kty: "RSA",
+			N:   encodeJWKInt(k.N),
+			E:   encodeJWKInt(big.NewInt(int64(k.E))),
+			D:   encodeJWKInt(k.D),
+			P:   encodeJWKInt(k.Primes[0]),
+			Q:   encodeJWKInt(k.Primes[1]),
+			DP:  encodeJWKInt(k.Precomputed.Dp),
+			DQ:  encodeJWKInt(k.Precomputed.Dq),
+			QI:  encodeJWKInt(k.Precomputed.Qinv),
+		}
+	case *rsa.PublicKey:
+		jwk = JWK{Kty: "RSA", N: encodeJWKInt(k.N), E: encodeJWKInt(big.NewInt(int64(k.E)))}
+	case []byte:
+		jwk = JWK{Kty: "oct", K: base64.RawURLEncoding.EncodeToString(k)}
+	default:
+		return nil, fmt.Errorf("%T has no JWK encoding", key)
+	}
+	return json.Marshal(jwk)
+}
+
+func parseJWK(data []byte) (any, error) {
+	var jwk JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, err
+	}
+
+	switch jwk.Kty {
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(jwk.K)
+	case "RSA":
+		n, err := decodeJWKInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeJWKInt(jwk.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		publicKey := rsa.PublicKey{N: n, E: int(e.Int64())}
+		if jwk.D == "" {
+			return &publicKey, nil
+		}
+
+		d, err := decodeJWKInt(jwk.D)
+		if err != nil {
+			return nil, fmt.Errorf("d: %w", err)
+		}
+		p, err := decodeJWKInt(jwk.P)
+		if err != nil {
+			return nil, fmt.Errorf("p: %w", err)
+		}
+		q, err := decodeJWKInt(jwk.Q)
+		if err != nil {
+			return nil, fmt.Errorf("q: %w", err)
+		}
+
+		privateKey := &rsa.PrivateKey{PublicKey: publicKey, D: d, Primes: []*big.Int{p, q}}
+		privateKey.Precompute()
+		if err := privateKey.Validate(); err != nil {
+			return nil, err
+		}
+		return privateKey, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+func encodeJWKInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// benchmarkKeySerialization measures encoding and parsing of keys in every
+// format that supports them, with one result per key and format.
+func benchmarkKeySerialization() []*KeyFormatResult {
+	config := defaultRunnerConfig
+
+	rsa2048 := benchmarkRSAKey(2048)
+	rsa3072 := benchmarkRSAKey(3072)
+	keys := []struct {
+		name string
+		key  any
+	}{
+		{"RSA-2048 private", rsa2048},
+		{"RSA-2048 public", &rsa2048.PublicKey},
+		{"RSA-3072 private", rsa3072},
+		{"RSA-3072 public", &rsa3072.PublicKey},
+		{"AES-256", benchmarkSymmetricKey("aes256", 32)},
+	}
+
+	results := []*KeyFormatResult{}
+	for _, format := range []string{keyFormatPEM, keyFormatDER, keyFormatJWK} {
+		for _, k := range keys {
+			encoded, err := encodeKey(k.key, format)
+			if err != nil {
+				continue
+			}
+			fmt.Printf("Running benchmarks for %s as %s (%d bytes)...\n", k.name, strings.ToUpper(format), len(encoded))
+
+			serialize := measureOperation(func() {
+				if _, err := encodeKey(k.key, format); err != nil {
+					log.Fatalf("Error encoding %s: %v", k.name, err)
+				}
+			}, config)
+			parse := measureOperation(func() {
+				if _, err := decodeKey(encoded, format); err != nil {
+					log.Fatalf("Error parsing %s: %v", k.name, err)
+				}
+			}, config)
+			results = append(results, &KeyFormatResult{k.name, format, len(encoded), serialize, parse})
+		}
+	}
+	return results
+}
+
+// exportKeyFormats writes a file per format and operation with a row per key,
+// sorted by the encoded size, which has a column of its own.
+func exportKeyFormats(results []*KeyFormatResult, dir string) {
+	results = slices.Clone(results)
+	slices.SortFunc(results, func(a, b *KeyFormatResult) int { return cmp.Compare(a.bytes, b.bytes) })
+
+	operations := []struct {
+		name   string
+		result func(*KeyFormatResult) *BenchmarkResult
+	}{
+		{"serialize", func(r *KeyFormatResult) *BenchmarkResult { return r.serialize }},
+		{"parse", func(r *KeyFormatResult) *BenchmarkResult { return r.parse }},
+	}
+
+	for _, format := range []string{keyFormatPEM, keyFormatDER, keyFormatJWK} {
+		for _, op := range operations {
+			header := append([]string{"Key"}, benchmarkResultHeader("")...)
+			header = append(header, "Encoded bytes")
+			records := [][]string{append(header, memoryStatsHeader("")...)}
+			raw := [][]string{rawSamplesHeader("Key")}
+			for _, r := range results {
+				if r.format != format {
+					continue
+				}
+				result := op.result(r)
+				row := append([]string{r.key}, benchmarkResultColumns(result)...)
+				row = append(row, fmt.Sprint(r.bytes))
+				records = append(records, append(row, memoryStatsColumns(result.memory)...))
+				raw = appendRawSamples(raw, r.key, "warm", result)
+			}
+
+			filename := filepath.Join(dir, op.name, format+".csv")
+			exportToCSV(filename, records)
+			if exportRawSamples {
+				exportToCSV(rawSamplesPath(filename), raw)
+			}
+		}
+	}
+}
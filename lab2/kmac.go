@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha3"
+	"hash"
+)
+
+// kmac implements KMAC128/KMAC256 from NIST SP 800-185 on top of cSHAKE.
+type kmac struct {
+	xof       *sha3.SHAKE
+	key       []byte
+	rate      int
+	outputLen int
+}
+
+func newKMAC128(key []byte, outputLen int, customization []byte) hash.Hash {
+	return newKMAC(sha3.NewCSHAKE128([]byte("KMAC"), customization), key, 168, outputLen)
+}
+
+func newKMAC256(key []byte, outputLen int, customization []byte) hash.Hash {
+	return newKMAC(sha3.NewCSHAKE256([]byte("KMAC"), customization), key, 136, outputLen)
+}
+
+func newKMAC(xof *sha3.SHAKE, key []byte, rate, outputLen int) hash.Hash {
+	k := &kmac{xof: xof, key: append([]byte(nil), key...), rate: rate, outputLen: outputLen}
+	k.Reset()
+	return k
+}
+
+func (k *kmac) Write(p []byte) (int, error) { return k.xof.Write(p) }
+func (k *kmac) Size() int                   { return k.outputLen }
+func (k *kmac) BlockSize() int              { return k.rate }
+
+func (k *kmac) Reset() {
+	k.xof.Reset()
+	k.xof.Write(bytepad(encodeString(k.key), k.rate))
+}
+
+func (k *kmac) Sum(b []byte) []byte {
+	state, err := k.xof.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := k.xof.UnmarshalBinary(state); err != nil {
+			panic(err)
+		}
+	}()
+
+	k.xof.Write(rightEncode(uint64(k.outputLen) * 8))
+	out := make([]byte, k.outputLen)
+	k.xof.Read(out)
+	return append(b, out...)
+}
+
+func leftEncode(x uint64) []byte {
+	buf := encodeUint(x)
+	return append([]byte{byte(len(buf))}, buf...)
+}
+
+func rightEncode(x uint64) []byte {
+	buf := encodeUint(x)
+	return append(buf, byte(len(buf)))
+}
+
+func encodeUint(x uint64) []byte {
+	n := 1
+	for v := x >> 8; v > 0; v >>= 8 {
+		n++
+	}
+	buf := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		buf[i] = byte(x)
+		x >>= 8
+	}
+	return buf
+}
+
+func encodeString(s []byte) []byte {
+	return append(leftEncode(uint64(len(s))*8), s...)
+}
+
+func bytepad(x []byte, w int) []byte {
+	buf := append(leftEncode(uint64(w)), x...)
+	if padLen := len(buf) % w; padLen != 0 {
+		buf = append(buf, make([]byte, w-padLen)...)
+	}
+	return buf
+}
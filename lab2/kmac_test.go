@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+// TestKMAC checks the KMAC samples of NIST SP 800-185 (KMAC_samples.pdf).
+func TestKMAC(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = 0x40 + byte(i)
+	}
+	short := []byte{0x00, 0x01, 0x02, 0x03}
+	long := make([]byte, 200)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	const tagged = "My Tagged Application"
+
+	samples := []struct {
+		name          string
+		newKMAC       func(key []byte, outputLen int, customization []byte) hash.Hash
+		data          []byte
+		customization string
+		want          string
+	}{
+		{"KMAC128 sample 1", newKMAC128, short, "",
+			"e5780b0d3ea6f7d3a429c5706aa43a00fadbd7d49628839e3187243f456ee14e"},
+		{"KMAC128 sample 2", newKMAC128, short, tagged,
+			"3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5"},
+		{"KMAC128 sample 3", newKMAC128, long, tagged,
+			"1f5b4e6cca02209e0dcb5ca635b89a15e271ecc760071dfd805faa38f9729230"},
+		{"KMAC256 sample 4", newKMAC256, short, tagged,
+			"20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7" +
+				"f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd"},
+		{"KMAC256 sample 5", newKMAC256, long, "",
+			"75358cf39e41494e949707927cee0af20a3ff553904c86b08f21cc414bcfd691" +
+				"589d27cf5e15369cbbff8b9a4c2eb17800855d0235ff635da82533ec6b759b69"},
+		{"KMAC256 sample 6", newKMAC256, long, tagged,
+			"b58618f71f92e1d56c1b8c55ddd7cd188b97b4ca4d99831eb2699a837da2e4d9" +
+				"70fbacfde50033aea585f1a2708510c32d07880801bd182898fe476876fc8965"},
+	}
+	for _, s := range samples {
+		t.Run(s.name, func(t *testing.T) {
+			h := s.newKMAC(key, len(s.want)/2, []byte(s.customization))
+			// written in two parts so that partial writes are covered too
+			h.Write(s.data[:len(s.data)/2])
+			h.Write(s.data[len(s.data)/2:])
+			if got := hex.EncodeToString(h.Sum(nil)); got != s.want {
+				t.Errorf("Sum = %s, want %s", got, s.want)
+			}
+
+			// Sum must not change the state and Reset must restart it
+			if got := hex.EncodeToString(h.Sum(nil)); got != s.want {
+				t.Errorf("second Sum = %s, want %s", got, s.want)
+			}
+			h.Reset()
+			h.Write(s.data)
+			if got := hex.EncodeToString(h.Sum(nil)); got != s.want {
+				t.Errorf("Sum after Reset = %s, want %s", got, s.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	mathrand "math/rand/v2"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// leakageThreshold is the |t| above which dudect reports a leak.
+	leakageThreshold = 4.5
+	leakageCropTests = 20
+)
+
+// LeakageTarget is one operation tested for timing leakage. Inputs of the
+// fixed class (0) and the random class (1) are prepared up front and are not
+// part of the measurement.
+type LeakageTarget struct {
+	name         string
+	measurements int
+	prepare      func(class int) []byte
+	operation    func([]byte)
+}
+
+// leakageSink keeps the compiler from eliminating inlined comparisons whose
+// result is otherwise unused.
+var leakageSink bool
+
+type LeakageResult struct {
+	name         string
+	measurements int
+	fixedMean    time.Duration
+	randomMean   time.Duration
+	maxT         float64
+	test         string
+}
+
+func runLeakageTests(args []string) {
+	fs := flag.NewFlagSet("leakage", flag.ExitOnError)
+	measurements := fs.Int("n", 0, "measurements per target (0 uses each target's default)")
+	only := fs.String("target", "", "only run targets whose name contains this string")
+	fs.Parse(args)
+
+	fmt.Println("=== TIMING LEAKAGE TESTS (dudect) ===")
+
+	results := []*LeakageResult{}
+	for _, target := range leakageTargets() {
+		if *only != "" && !strings.Contains(target.name, *only) {
+			continue
+		}
+		if *measurements > 0 {
+			target.measurements = *measurements
+		}
+
+		fmt.Printf("Testing %s with %d measurements...\n", target.name, target.measurements)
+		results = append(results, measureLeakage(target))
+	}
+
+	fmt.Println()
+	printLeakageResults(results)
+	exportLeakage(results, "results/leakage/leakage.csv")
+}
+
+func leakageTargets() []*LeakageTarget {
+	secret := randomBytes(32)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalf("Error generating RSA key: %v", err)
+	}
+	oaepCiphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &rsaKey.PublicKey, randomBytes(32), nil)
+	if err != nil {
+		log.Fatalf("Error encrypting: %v", err)
+	}
+	pkcs1Ciphertext, err := rsa.EncryptPKCS1v15(rand.Reader, &rsaKey.PublicKey, randomBytes(32))
+	if err != nil {
+		log.Fatalf("Error encrypting: %v", err)
+	}
+
+	return []*LeakageTarget{
+		{
+			name: "pkcs7Unpad (8-byte blocks)", measurements: 200000,
+			prepare:   paddedOrRandom(64, 8),
+			operation: func(in []byte) { pkcs7Unpad(in, 8) },
+		},
+		{
+			name: "pkcs7Unpad (16-byte blocks)", measurements: 200000,
+			prepare:   paddedOrRandom(64, 16),
+			operation: func(in []byte) { pkcs7Unpad(in, 16) },
+		},
+		{
+			name: "naive compare", measurements: 200000,
+			prepare:   equalOrRandom(secret),
+			operation: func(in []byte) { leakageSink = naiveEqual(secret, in) },
+		},
+		{
+			name: "bytes.Equal", measurements: 200000,
+			prepare:   equalOrRandom(secret),
+			operation: func(in []byte) { leakageSink = bytes.Equal(secret, in) },
+		},
+		{
+			name: "subtle.ConstantTimeCompare", measurements: 200000,
+			prepare:   equalOrRandom(secret),
+			operation: func(in []byte) { leakageSink = subtle.ConstantTimeCompare(secret, in) == 1 },
+		},
+		{
+			name: "RSA-OAEP-2048 decrypt", measurements: 4000,
+			prepare:   ciphertextOrRandom(oaepCiphertext),
+			operation: func(in []byte) { rsa.DecryptOAEP(sha256.New(), nil, rsaKey, in, nil) },
+		},
+		{
+			name: "RSA-PKCS1v15-2048 decrypt", measurements: 4000,
+			prepare:   ciphertextOrRandom(pkcs1Ciphertext),
+			operation: func(in []byte) { rsa.DecryptPKCS1v15(nil, rsaKey, in) },
+		},
+	}
+}
+
+// paddedOrRandom returns inputs with a full block of valid PKCS#7 padding
+// (fixed class) or uniformly random bytes, which are almost never validly
+// padded (random class).
+func paddedOrRandom(size, blockSize int) func(int) []byte {
+	fixed := randomBytes(size)
+	for i := size - blockSize; i < size; i++ {
+		fixed[i] = byte(blockSize)
+	}
+	return func(class int) []byte {
+		if class == 0 {
+			return slices.Clone(fixed)
+		}
+		return randomBytes(size)
+	}
+}
+
+// equalOrRandom returns the secret itself (fixed class) or random bytes that
+// differ from it early on (random class).
+func equalOrRandom(secret []byte) func(int) []byte {
+	return func(class int) []byte {
+		if class == 0 {
+			return slices.Clone(secret)
+		}
+		return randomBytes(len(secret))
+	}
+}
+
+// ciphertextOrRandom returns a valid ciphertext (fixed class) or a random
+// value below the modulus that fails the padding check (random class).
+func ciphertextOrRandom(ciphertext []byte) func(int) []byte {
+	return func(class int) []byte {
+		if class == 0 {
+			return slices.Clone(ciphertext)
+		}
+		in := randomBytes(len(ciphertext))
+		in[0] = 0
+		return in
+	}
+}
+
+func naiveEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Error generating random data: %v", err)
+	}
+	return b
+}
+
+// measureLeakage follows dudect (Reparaz, Balasch, Verbauwhede 2017): classes
+// are interleaved at random, every call is timed on its own, and Welch's
+// t-test is applied to the full distributions and to versions cropped at
+// increasing percentiles, which removes the long tail caused by interrupts.
+// GC is paused while measuring so that collections do not add noise.
+func measureLeakage(target *LeakageTarget) *LeakageResult {
+	classes := make([]int, target.measurements)
+	inputs := make([][]byte, target.measurements)
+	for i := range classes {
+		classes[i] = mathrand.IntN(2)
+		inputs[i] = target.prepare(classes[i])
+	}
+
+	for i := range warmUpIterations {
+		target.operation(inputs[i%len(inputs)])
+	}
+
+	durations := make([]time.Duration, target.measurements)
+
+	runtime.GC()
+	gcPercent := debug.SetGCPercent(-1)
+	for i, in := range inputs {
+		start := time.Now()
+		target.operation(in)
+		durations[i] = time.Since(start)
+	}
+	debug.SetGCPercent(gcPercent)
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	result := &LeakageResult{name: target.name, measurements: target.measurements, test: "uncropped"}
+	for test := 0; test <= leakageCropTests; test++ {
+		threshold := time.Duration(math.MaxInt64)
+		name := "uncropped"
+		if test > 0 {
+			percentile := 1 - math.Pow(0.5, 10*float64(test)/leakageCropTests)
+			threshold = sorted[int(percentile*float64(len(sorted)-1))]
+			name = fmt.Sprintf("cropped at p%.1f", percentile*100)
+		}
+
+		var fixed, random runningStats
+		for i, duration := range durations {
+			if duration > threshold {
+				continue
+			}
+			if classes[i] == 0 {
+				fixed.add(float64(duration))
+			} else {
+				random.add(float64(duration))
+			}
+		}
+
+		t := welchT(fixed, random)
+		if test == 0 {
+			result.fixedMean = time.Duration(fixed.mean)
+			result.randomMean = time.Duration(random.mean)
+		}
+		if math.Abs(t) > math.Abs(result.maxT) {
+			result.maxT = t
+			result.test = name
+		}
+	}
+
+	return result
+}
+
+func welchT(a, b runningStats) float64 {
+	if a.n < 2 || b.n < 2 {
+		return 0
+	}
+	varA := a.m2 / float64(a.n-1)
+	varB := b.m2 / float64(b.n-1)
+	denominator := math.Sqrt(varA/float64(a.n) + varB/float64(b.n))
+	if denominator == 0 {
+		return 0
+	}
+	return (a.mean - b.mean) / denominator
+}
+
+func leakageVerdict(result *LeakageResult) string {
+	if math.Abs(result.maxT) >= leakageThreshold {
+		return "LEAKS"
+	}
+	return "no leakage detected"
+}
+
+func printLeakageResults(results []*LeakageResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Target\tMeasurements\tFixed mean\tRandom mean\tmax |t|\tTest\tVerdict\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%.2f\t%s\t%s\t\n",
+			r.name, r.measurements, r.fixedMean, r.randomMean, math.Abs(r.maxT), r.test, leakageVerdict(r))
+	}
+	w.Flush()
+	fmt.Printf("\n|t| >= %.1f means the timing distributions of the two input classes differ.\n", leakageThreshold)
+}
+
+func exportLeakage(results []*LeakageResult, filename string) {
+	records := [][]string{{"Target", "Measurements", "Fixed mean (ns)", "Random mean (ns)", "t", "Test", "Verdict"}}
+	for _, r := range results {
+		records = append(records, []string{
+			r.name,
+			fmt.Sprint(r.measurements),
+			fmt.Sprint(r.fixedMean.Nanoseconds()),
+			fmt.Sprint(r.randomMean.Nanoseconds()),
+			fmt.Sprintf("%.4f", r.maxT),
+			r.test,
+			leakageVerdict(r),
+		})
+	}
+	exportToCSV(filename, records)
+}
@@ -8,10 +8,13 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"os"
+	"path/filepath"
+	"runtime"
 	"slices"
 	"time"
 )
@@ -21,6 +24,17 @@ type BenchmarkResult struct {
 	median       time.Duration
 	percentile95 time.Duration
 	totalTime    time.Duration
+	stddev       time.Duration
+	min          time.Duration
+	max          time.Duration
+	mad          time.Duration
+	ciLow        time.Duration
+	ciHigh       time.Duration
+	outliers     int
+	samples      int
+	batchSize    int
+	durations    []time.Duration
+	memory       MemoryStats
 }
 
 type AlgorithmKeyGenResult struct {
@@ -32,64 +46,241 @@ type AlgorithmKeyGenResult struct {
 type AlgorithmEncryptResult struct {
 	bytes  int
 	result *BenchmarkResult
+	cold   *BenchmarkResult
 }
 
-type EncryptFunc func([]byte) (time.Duration, time.Duration)
+// EncryptFunc prepares the two operations of a benchmark (encrypt and
+// decrypt, sign and verify, ...) for one input, e.g. by encrypting it once so
+// that decryption has a ciphertext to work on. The returned operations do no
+// timing of their own; the runner times whole batches of them.
+type EncryptFunc func([]byte) (func(), func())
 
-func main() {
-	if len(os.Args) > 1 && os.Args[1] == "benchmarks" {
-		fmt.Println("Starting cryptographic benchmarks...")
-		fmt.Println()
-
-		fmt.Println("=== KEY GENERATION BENCHMARKS ===")
-		rsaKeyGen, aesKeyGen, desKeyGen := benchmarkKeyGeneration()
+// SetupFunc prepares key material and cipher contexts for one benchmark
+// configuration. It returns the steady-state operation and a teardown that
+// releases whatever the setup created.
+type SetupFunc func() (EncryptFunc, func())
 
-		fmt.Println("\n=== ENCRYPTION/DECRYPTION BENCHMARKS ===")
-		encryptResults := benchmarkEncryptionDecryption()
+const (
+	warmUpIterations = 2
+	coldIterations   = 8
+)
 
-		fmt.Println("=== EXPORT ===")
+// exportRawSamples enables per-sample dumps written next to the summary CSVs.
+var exportRawSamples bool
 
-		fmt.Println("Exporting key gen results...")
-		keyGenExports := map[string][]*AlgorithmKeyGenResult{
-			"results/keygen/rsa2048.csv": filterAKGRByBits(rsaKeyGen, 2048),
-			"results/keygen/rsa3072.csv": filterAKGRByBits(rsaKeyGen, 3072),
-			"results/keygen/aes128.csv":  filterAKGRByBits(aesKeyGen, 128),
-			"results/keygen/aes256.csv":  filterAKGRByBits(aesKeyGen, 256),
-			"results/keygen/des192.csv":  desKeyGen,
-		}
-		for path, data := range keyGenExports {
-			exportAKGR(data, path)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "benchmarks":
+			runBenchmarks(os.Args[2:])
+		case "kdf":
+			runKDFBenchmarks(os.Args[2:])
+		case "parallel":
+			runParallelBenchmarks(os.Args[2:])
+		case "scratch":
+			runScratchCiphers(os.Args[2:])
+		case "modes":
+			runModeBenchmarks(os.Args[2:])
+		case "plots":
+			runDrawPlots(os.Args[2:])
+		case "rsa-sweep":
+			runRSASweep(os.Args[2:])
+		case "stream":
+			runStreamingBenchmarks(os.Args[2:])
+		case "compare":
+			runCompare(os.Args[2:])
+			return
+		case "leakage":
+			runLeakageTests(os.Args[2:])
+			return
+		case "toy-rsa":
+			runToyRSA(os.Args[2:])
+			return
+		case "verify":
+			runVerify(os.Args[2:])
+			return
+		case "report":
+			runReport(os.Args[2:])
+			return
+		case "padding-oracle":
+			runPaddingOracle(os.Args[2:])
+			return
+		case "encrypt":
+			runEncryptFile(os.Args[2:])
+			return
+		case "decrypt":
+			runDecryptFile(os.Args[2:])
+			return
+		case "keygen":
+			runKeyGenFile(os.Args[2:])
+			return
 		}
+	}
 
-		fmt.Println("Exporting encryption results...")
-		encryptExports := map[string][]*AlgorithmEncryptResult{
-			"results/encryption/rsa2048.csv": encryptResults["RSA-2048"].encrypt,
-			"results/encryption/aes128.csv":  encryptResults["AES-128-GCM"].encrypt,
-			"results/encryption/aes256.csv":  encryptResults["AES-256-GCM"].encrypt,
-			"results/encryption/3des192.csv": encryptResults["3DES-CBC"].encrypt,
-		}
-		for path, data := range encryptExports {
-			exportAER(data, path)
-		}
+	fmt.Println("Drawing plots...")
+	drawAll()
+}
 
-		fmt.Println("Exporting decryption results...")
-		decryptExports := map[string][]*AlgorithmEncryptResult{
-			"results/decryption/rsa2048.csv": encryptResults["RSA-2048"].decrypt,
-			"results/decryption/aes128.csv":  encryptResults["AES-128-GCM"].decrypt,
-			"results/decryption/aes256.csv":  encryptResults["AES-256-GCM"].decrypt,
-			"results/decryption/3des192.csv": encryptResults["3DES-CBC"].decrypt,
+func runBenchmarks(args []string) {
+	fs := flag.NewFlagSet("benchmarks", flag.ExitOnError)
+	plotFormatFlag(fs)
+	fs.BoolVar(&exportRawSamples, "raw", false, "also write every sample to results/<category>/raw/")
+	fs.BoolVar(&captureProfiles, "pprof", false, "write CPU and heap profiles per algorithm and size to results/<category>/pprof/ (slows down measurement)")
+	saveKeys := fs.String("save-keys", "", "write every generated key to this directory")
+	keyFormat := fs.String("key-format", keyFormatPEM, "format of saved RSA keys: pem, der or jwk")
+	loadKeys := fs.String("load-keys", "", "use the keys in this directory (e.g. rsa2048_0001.pem, aes256_0001.jwk) for the encryption benchmarks")
+	fs.Parse(args)
+
+	if *saveKeys != "" {
+		store, err := newKeyStore(*saveKeys, *keyFormat)
+		if err != nil {
+			log.Fatalf("error preparing key directory: %v", err)
 		}
-		for path, data := range decryptExports {
-			exportAER(data, path)
+		keyStore = store
+	}
+	if *loadKeys != "" {
+		keys, err := loadKeyDir(*loadKeys)
+		if err != nil {
+			log.Fatalf("error loading keys: %v", err)
 		}
+		loadedKeys = keys
+		fmt.Printf("Loaded %d keys from %s\n", len(keys), *loadKeys)
 	}
 
-	fmt.Println("Drawing plots...")
-	drawAll()
+	fmt.Println("Starting cryptographic benchmarks...")
+	fmt.Println()
+
+	fmt.Println("=== KEY GENERATION BENCHMARKS ===")
+	rsaKeyGen, aesKeyGen, desKeyGen := benchmarkKeyGeneration()
+
+	fmt.Println("\n=== ENCRYPTION/DECRYPTION BENCHMARKS ===")
+	encryptResults := benchmarkEncryptionDecryption()
+
+	fmt.Println("\n=== SIGNING/VERIFICATION BENCHMARKS ===")
+	signResults := benchmarkSigning()
+
+	fmt.Println("\n=== HASHING/MAC BENCHMARKS ===")
+	hashResults := benchmarkHashing()
+
+	fmt.Println("\n=== KEY SERIALIZATION BENCHMARKS ===")
+	keyFormatResults := benchmarkKeySerialization()
+
+	fmt.Println("=== EXPORT ===")
+
+	fmt.Println("Exporting key gen results...")
+	keyGenExports := map[string][]*AlgorithmKeyGenResult{
+		"results/keygen/rsa2048.csv": filterAKGRByBits(rsaKeyGen, 2048),
+		"results/keygen/rsa3072.csv": filterAKGRByBits(rsaKeyGen, 3072),
+		"results/keygen/aes128.csv":  filterAKGRByBits(aesKeyGen, 128),
+		"results/keygen/aes256.csv":  filterAKGRByBits(aesKeyGen, 256),
+		"results/keygen/des192.csv":  desKeyGen,
+	}
+	for path, data := range keyGenExports {
+		exportAKGR(data, path)
+	}
+
+	fmt.Println("Exporting encryption results...")
+	for algoName, algo := range encryptionAlgorithms {
+		exportAER(encryptResults[algoName].encrypt, "results/encryption/"+algo.file)
+	}
+
+	fmt.Println("Exporting decryption results...")
+	for algoName, algo := range encryptionAlgorithms {
+		exportAER(encryptResults[algoName].decrypt, "results/decryption/"+algo.file)
+	}
+
+	fmt.Println("Exporting signing results...")
+	signExports := map[string][]*AlgorithmEncryptResult{
+		"results/signing/sign/rsapss2048.csv":       signResults["RSA-PSS-2048"].sign,
+		"results/signing/sign/rsapkcs1v15_2048.csv": signResults["RSA-PKCS1v15-2048"].sign,
+		"results/signing/sign/ecdsap256.csv":        signResults["ECDSA-P256"].sign,
+		"results/signing/sign/ed25519.csv":          signResults["Ed25519"].sign,
+		"results/signing/sign/mldsa65.csv":          signResults["ML-DSA-65"].sign,
+	}
+	for path, data := range signExports {
+		exportAER(data, path)
+	}
+
+	fmt.Println("Exporting verification results...")
+	verifyExports := map[string][]*AlgorithmEncryptResult{
+		"results/signing/verify/rsapss2048.csv":       signResults["RSA-PSS-2048"].verify,
+		"results/signing/verify/rsapkcs1v15_2048.csv": signResults["RSA-PKCS1v15-2048"].verify,
+		"results/signing/verify/ecdsap256.csv":        signResults["ECDSA-P256"].verify,
+		"results/signing/verify/ed25519.csv":          signResults["Ed25519"].verify,
+		"results/signing/verify/mldsa65.csv":          signResults["ML-DSA-65"].verify,
+	}
+	for path, data := range verifyExports {
+		exportAER(data, path)
+	}
+
+	fmt.Println("Exporting hashing results...")
+	hashExports := map[string][]*AlgorithmEncryptResult{
+		"results/hashing/compute/sha256.csv":        hashResults["SHA-256"].compute,
+		"results/hashing/compute/sha512.csv":        hashResults["SHA-512"].compute,
+		"results/hashing/compute/sha3_256.csv":      hashResults["SHA3-256"].compute,
+		"results/hashing/compute/blake2b256.csv":    hashResults["BLAKE2b-256"].compute,
+		"results/hashing/compute/ascon_hash256.csv": hashResults["Ascon-Hash256"].compute,
+		"results/hashing/compute/hmac_sha256.csv":   hashResults["HMAC-SHA256"].compute,
+		"results/hashing/compute/kmac128.csv":       hashResults["KMAC128"].compute,
+		"results/hashing/compute/kmac256.csv":       hashResults["KMAC256"].compute,
+		"results/hashing/verify/sha256.csv":         hashResults["SHA-256"].verify,
+		"results/hashing/verify/sha512.csv":         hashResults["SHA-512"].verify,
+		"results/hashing/verify/sha3_256.csv":       hashResults["SHA3-256"].verify,
+		"results/hashing/verify/blake2b256.csv":     hashResults["BLAKE2b-256"].verify,
+		"results/hashing/verify/ascon_hash256.csv":  hashResults["Ascon-Hash256"].verify,
+		"results/hashing/verify/hmac_sha256.csv":    hashResults["HMAC-SHA256"].verify,
+		"results/hashing/verify/kmac128.csv":        hashResults["KMAC128"].verify,
+		"results/hashing/verify/kmac256.csv":        hashResults["KMAC256"].verify,
+	}
+	for path, data := range hashExports {
+		exportAER(data, path)
+	}
+
+	fmt.Println("Exporting key serialization results...")
+	exportKeyFormats(keyFormatResults, "results/keyformat")
+
+	fmt.Println("Exporting JSON and benchstat results...")
+	doc := newResultsDocument()
+	doc.addKeyGenResults("RSA-2048", filterAKGRByBits(rsaKeyGen, 2048))
+	doc.addKeyGenResults("RSA-3072", filterAKGRByBits(rsaKeyGen, 3072))
+	doc.addKeyGenResults("AES-128", filterAKGRByBits(aesKeyGen, 128))
+	doc.addKeyGenResults("AES-256", filterAKGRByBits(aesKeyGen, 256))
+	doc.addKeyGenResults("3DES-192", desKeyGen)
+	for name, r := range encryptResults {
+		doc.addEncryptResults("encryption", name, r.encrypt)
+		doc.addEncryptResults("decryption", name, r.decrypt)
+	}
+	for name, r := range signResults {
+		doc.addEncryptResults("sign", name, r.sign)
+		doc.addEncryptResults("verify", name, r.verify)
+	}
+	for name, r := range hashResults {
+		doc.addEncryptResults("hash", name, r.compute)
+		doc.addEncryptResults("hash_verify", name, r.verify)
+	}
+	for _, r := range keyFormatResults {
+		doc.addKeyFormatResult("key_serialize", r, r.serialize)
+		doc.addKeyFormatResult("key_parse", r, r.parse)
+	}
+	exportJSON("results/results.json", doc)
+	exportBenchstat("results/benchstat.txt", doc)
 }
 
-func exportToCSV(filepath string, records [][]string) {
-	f, err := os.Create(filepath)
+// exportToCSV writes a measured result file together with the sidecar
+// describing the environment it was measured in.
+func exportToCSV(filename string, records [][]string) {
+	writeCSV(filename, records)
+	if err := writeEnvironment(filename); err != nil {
+		log.Fatalf("error writing environment: %v", err)
+	}
+}
+
+func writeCSV(filename string, records [][]string) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		log.Fatalf("error during directory creation: %v", err)
+	}
+
+	f, err := os.Create(filename)
 	if err != nil {
 		log.Fatalf("error during file creation: %v", err)
 	}
@@ -106,30 +297,96 @@ func exportToCSV(filepath string, records [][]string) {
 }
 
 func exportAKGR(results []*AlgorithmKeyGenResult, filepath string) {
-	records := [][]string{{"Number of keys", "Mean", "Median", "95. Percentile", "Total time"}}
+	header := append([]string{"Number of keys"}, benchmarkResultHeader("")...)
+	records := [][]string{append(header, memoryStatsHeader("")...)}
 	for _, result := range results {
-		records = append(records, []string{
-			fmt.Sprint(result.keysNum),
-			result.result.mean.String(),
-			result.result.median.String(),
-			result.result.percentile95.String(),
-			result.result.totalTime.String(),
-		})
+		row := append([]string{fmt.Sprint(result.keysNum)}, benchmarkResultColumns(result.result)...)
+		records = append(records, append(row, memoryStatsColumns(result.result.memory)...))
 	}
 	exportToCSV(filepath, records)
+
+	if exportRawSamples {
+		raw := [][]string{rawSamplesHeader("Number of keys")}
+		for _, result := range results {
+			raw = appendRawSamples(raw, fmt.Sprint(result.keysNum), "warm", result.result)
+		}
+		exportToCSV(rawSamplesPath(filepath), raw)
+	}
 }
 
 func exportAER(results []*AlgorithmEncryptResult, filepath string) {
-	records := [][]string{{"Bytes", "Mean", "Median", "95. Percentile"}}
+	// memory columns come last so the cold columns keep their position
+	header := append([]string{"Bytes"}, benchmarkResultHeader("")...)
+	header = append(header, benchmarkResultHeader("Cold ")...)
+	header = append(header, memoryStatsHeader("")...)
+	records := [][]string{append(header, memoryStatsHeader("Cold ")...)}
 	for _, result := range results {
-		records = append(records, []string{
-			fmt.Sprint(result.bytes),
-			result.result.mean.String(),
-			result.result.median.String(),
-			result.result.percentile95.String(),
-		})
+		cold := result.cold
+		if cold == nil {
+			cold = &BenchmarkResult{}
+		}
+		row := append([]string{fmt.Sprint(result.bytes)}, benchmarkResultColumns(result.result)...)
+		row = append(row, benchmarkResultColumns(cold)...)
+		row = append(row, memoryStatsColumns(result.result.memory)...)
+		records = append(records, append(row, memoryStatsColumns(cold.memory)...))
 	}
 	exportToCSV(filepath, records)
+
+	if exportRawSamples {
+		raw := [][]string{rawSamplesHeader("Bytes")}
+		for _, result := range results {
+			raw = appendRawSamples(raw, fmt.Sprint(result.bytes), "warm", result.result)
+			if result.cold != nil {
+				raw = appendRawSamples(raw, fmt.Sprint(result.bytes), "cold", result.cold)
+			}
+		}
+		exportToCSV(rawSamplesPath(filepath), raw)
+	}
+}
+
+// benchmarkResultHeader and benchmarkResultColumns keep every duration as an
+// integer number of nanoseconds so the CSVs can be loaded without parsing
+// Go duration strings.
+func benchmarkResultHeader(prefix string) []string {
+	return []string{
+		prefix + "Mean (ns)", prefix + "Median (ns)", prefix + "95. Percentile of batch means (ns)", prefix + "Total time (ns)",
+		prefix + "Stddev (ns)", prefix + "Min batch mean (ns)", prefix + "Max batch mean (ns)", prefix + "MAD (ns)",
+		prefix + "95% CI low (ns)", prefix + "95% CI high (ns)",
+		prefix + "Outliers", prefix + "Samples", prefix + "Batch size",
+	}
+}
+
+func benchmarkResultColumns(result *BenchmarkResult) []string {
+	return []string{
+		fmt.Sprint(result.mean.Nanoseconds()),
+		fmt.Sprint(result.median.Nanoseconds()),
+		fmt.Sprint(result.percentile95.Nanoseconds()),
+		fmt.Sprint(result.totalTime.Nanoseconds()),
+		fmt.Sprint(result.stddev.Nanoseconds()),
+		fmt.Sprint(result.min.Nanoseconds()),
+		fmt.Sprint(result.max.Nanoseconds()),
+		fmt.Sprint(result.mad.Nanoseconds()),
+		fmt.Sprint(result.ciLow.Nanoseconds()),
+		fmt.Sprint(result.ciHigh.Nanoseconds()),
+		fmt.Sprint(result.outliers),
+		fmt.Sprint(result.samples),
+		fmt.Sprint(result.batchSize),
+	}
+}
+
+func rawSamplesHeader(keyColumn string) []string {
+	return []string{keyColumn, "Phase", "Sample", "Batch mean (ns)", "Batch size"}
+}
+
+func appendRawSamples(records [][]string, key, phase string, result *BenchmarkResult) [][]string {
+	for i, duration := range result.durations {
+		records = append(records, []string{key, phase, fmt.Sprint(i), fmt.Sprint(duration.Nanoseconds()), fmt.Sprint(result.batchSize)})
+	}
+	return records
+}
+
+func rawSamplesPath(filename string) string {
+	return filepath.Join(filepath.Dir(filename), "raw", filepath.Base(filename))
 }
 
 func filterAKGRByBits(results []*AlgorithmKeyGenResult, bits int) []*AlgorithmKeyGenResult {
@@ -142,13 +399,14 @@ func filterAKGRByBits(results []*AlgorithmKeyGenResult, bits int) []*AlgorithmKe
 	return res
 }
 
-func runKeyGenBenchmark(name string, keyNums []int, bitsToTest []int, measureFunc func(nums, bits int) []time.Duration) []*AlgorithmKeyGenResult {
+func runKeyGenBenchmark(name string, keyNums []int, bitsToTest []int, measureFunc func(nums, bits int) ([]time.Duration, MemoryStats)) []*AlgorithmKeyGenResult {
 	results := make([]*AlgorithmKeyGenResult, 0)
 	for _, nums := range keyNums {
 		for _, bits := range bitsToTest {
 			fmt.Printf("Calculating for %s; %d bits; %d keys\n", name, bits, nums)
-			durations := measureFunc(nums, bits)
+			durations, memory := measureFunc(nums, bits)
 			result := calculateBenchmarkResult(durations)
+			result.memory = memory
 			results = append(results, &AlgorithmKeyGenResult{bits, nums, result})
 		}
 	}
@@ -168,101 +426,169 @@ func benchmarkKeyGeneration() ([]*AlgorithmKeyGenResult, []*AlgorithmKeyGenResul
 	return rsaKeyGenResults, aesKeyGenResults, desKeyGenResults
 }
 
+type EncryptionAlgorithm struct {
+	setup     SetupFunc
+	dataSizes []int
+	file      string
+}
+
+var (
+	symmetricDataSizes = []int{128, 512, 2 * 1024, 8 * 1024, 32 * 1024, 1024 * 1024, 4 * 1024 * 1024, 16 * 1024 * 1024}
+	rsaDataSizes       = []int{16, 32, 64, 128, 190}
+)
+
+// encryptionAlgorithms is shared by the encryption and the parallel
+// benchmarks; file is the name of the result file in their directories.
+var encryptionAlgorithms = map[string]EncryptionAlgorithm{
+	"RSA-2048": {
+		setup:     func() (EncryptFunc, func()) { return setupEncryptRSA(2048) },
+		dataSizes: rsaDataSizes,
+		file:      "rsa2048.csv",
+	},
+	"Toy-RSA-OAEP-2048": {
+		setup:     func() (EncryptFunc, func()) { return setupEncryptToyRSA(2048, true) },
+		dataSizes: rsaDataSizes,
+		file:      "toy_rsa2048.csv",
+	},
+	"Toy-RSA-OAEP-2048-noCRT": {
+		setup:     func() (EncryptFunc, func()) { return setupEncryptToyRSA(2048, false) },
+		dataSizes: rsaDataSizes,
+		file:      "toy_rsa2048_nocrt.csv",
+	},
+	"AES-128-GCM": {
+		setup:     func() (EncryptFunc, func()) { return setupEncryptAESGCM(128) },
+		dataSizes: symmetricDataSizes,
+		file:      "aes128.csv",
+	},
+	"AES-256-GCM": {
+		setup:     func() (EncryptFunc, func()) { return setupEncryptAESGCM(256) },
+		dataSizes: symmetricDataSizes,
+		file:      "aes256.csv",
+	},
+	"3DES-CBC": {
+		setup:     func() (EncryptFunc, func()) { return setupEncrypt3DESCBC(192) },
+		dataSizes: symmetricDataSizes,
+		file:      "3des192.csv",
+	},
+	"RSA-OAEP-2048+AES-256-GCM": {
+		setup:     func() (EncryptFunc, func()) { return setupEncryptHybridRSA(2048) },
+		dataSizes: symmetricDataSizes,
+		file:      "hybrid_rsa2048_aes256.csv",
+	},
+	"X25519+HKDF+AES-256-GCM": {
+		setup:     setupEncryptHybridX25519,
+		dataSizes: symmetricDataSizes,
+		file:      "hybrid_x25519_aes256.csv",
+	},
+	"ML-KEM-768+AES-256-GCM": {
+		setup:     setupEncryptHybridMLKEM768,
+		dataSizes: symmetricDataSizes,
+		file:      "hybrid_mlkem768_aes256.csv",
+	},
+}
+
 func benchmarkEncryptionDecryption() map[string]struct {
 	encrypt []*AlgorithmEncryptResult
 	decrypt []*AlgorithmEncryptResult
 } {
-	iterations := 32
-
-	symmetricDataSizes := []int{128, 512, 2 * 1024, 8 * 1024, 32 * 1024, 1024 * 1024, 4 * 1024 * 1024, 16 * 1024 * 1024}
-	rsaDataSizes := []int{16, 32, 64, 128, 190}
-
-	algorithms := map[string]struct {
-		measureFunc EncryptFunc
-		dataSizes   []int
-	}{
-		"RSA-2048": {
-			measureFunc: measureEncryptRSA,
-			dataSizes:   rsaDataSizes,
-		},
-		"AES-128-GCM": {
-			measureFunc: func(data []byte) (time.Duration, time.Duration) {
-				return measureEncryptAESGCM(data, 128)
-			},
-			dataSizes: symmetricDataSizes,
-		},
-		"AES-256-GCM": {
-			measureFunc: func(data []byte) (time.Duration, time.Duration) {
-				return measureEncryptAESGCM(data, 256)
-			},
-			dataSizes: symmetricDataSizes,
-		},
-		"3DES-CBC": {
-			measureFunc: func(data []byte) (time.Duration, time.Duration) {
-				return measureEncrypt3DESCBC(data, 192)
-			},
-			dataSizes: symmetricDataSizes,
-		},
-	}
+	config := defaultRunnerConfig
 
 	results := make(map[string]struct {
 		encrypt []*AlgorithmEncryptResult
 		decrypt []*AlgorithmEncryptResult
 	})
 
-	for name, algo := range algorithms {
-		results[name] = struct {
-			encrypt []*AlgorithmEncryptResult
-			decrypt []*AlgorithmEncryptResult
-		}{
-			encrypt: make([]*AlgorithmEncryptResult, 0, len(algo.dataSizes)),
-			decrypt: make([]*AlgorithmEncryptResult, 0, len(algo.dataSizes)),
-		}
-	}
-
-	for algoName, algo := range algorithms {
+	for algoName, algo := range encryptionAlgorithms {
 		fmt.Printf("Running benchmarks for %s...\n", algoName)
 
-		for _, bytes := range algo.dataSizes {
-			data := make([]byte, bytes)
-			_, err := rand.Read(data)
-			if err != nil {
-				log.Fatalf("Error generating random data: %v", err)
-			}
+		config.profilePrefix = profilePrefix("results/encryption", algoName)
+		r := results[algoName]
+		r.encrypt, r.decrypt = runDataSizesBenchmark(algo.dataSizes, algo.setup, config)
+		results[algoName] = r
+	}
 
-			encryptResult, decryptResult := runBenchmark(data, algo.measureFunc, iterations)
+	return results
+}
 
-			r := results[algoName]
-			r.encrypt = append(r.encrypt, &AlgorithmEncryptResult{bytes, encryptResult})
-			r.decrypt = append(r.decrypt, &AlgorithmEncryptResult{bytes, decryptResult})
-			results[algoName] = r
+func runDataSizesBenchmark(dataSizes []int, setup SetupFunc, config RunnerConfig) ([]*AlgorithmEncryptResult, []*AlgorithmEncryptResult) {
+	firstResults := make([]*AlgorithmEncryptResult, 0, len(dataSizes))
+	secondResults := make([]*AlgorithmEncryptResult, 0, len(dataSizes))
+
+	for _, bytes := range dataSizes {
+		data := make([]byte, bytes)
+		_, err := rand.Read(data)
+		if err != nil {
+			log.Fatalf("Error generating random data: %v", err)
 		}
+
+		firstCold, secondCold := runColdBenchmark(data, setup, coldIterations)
+
+		stopProfile := startProfile(config.profilePrefix, bytes)
+		measureFunc, teardown := setup()
+		firstResult, secondResult := runBenchmark(data, measureFunc, config)
+		teardown()
+		stopProfile()
+
+		fmt.Printf("  %d bytes: %d samples x %d ops, mean %v (95%% CI %v..%v), %d outliers, %.1f allocs/op\n",
+			bytes, firstResult.samples, firstResult.batchSize, firstResult.mean, firstResult.ciLow, firstResult.ciHigh, firstResult.outliers,
+			firstResult.memory.allocsPerOp)
+
+		firstResults = append(firstResults, &AlgorithmEncryptResult{bytes, firstResult, firstCold})
+		secondResults = append(secondResults, &AlgorithmEncryptResult{bytes, secondResult, secondCold})
 	}
 
-	return results
+	return firstResults, secondResults
 }
 
-func runBenchmark(data []byte, measureFunc EncryptFunc, iterations int) (*BenchmarkResult, *BenchmarkResult) {
-	encryptDurations := make([]time.Duration, 0, iterations-2)
-	decryptDurations := make([]time.Duration, 0, iterations-2)
+// runColdBenchmark measures a freshly set up configuration: every iteration
+// pays for the setup followed by a single operation. The setup is counted in
+// the time and allocations of both operations, but each operation's own
+// allocations only in its result.
+func runColdBenchmark(data []byte, setup SetupFunc, iterations int) (*BenchmarkResult, *BenchmarkResult) {
+	encryptDurations := make([]time.Duration, 0, iterations)
+	decryptDurations := make([]time.Duration, 0, iterations)
+	var encryptMemory, decryptMemory MemoryStats
 
-	for i := range iterations {
-		encryptDuration, decryptDuration := measureFunc(data)
+	for range iterations {
+		var measureFunc EncryptFunc
+		var teardown func()
+		setupDuration, setupMemory := measureOnce(func() { measureFunc, teardown = setup() }, iterations)
 
-		if i < 2 {
-			continue
-		}
+		encrypt, decrypt := measureFunc(data)
+		encryptDuration, encryptOpMemory := measureOnce(encrypt, iterations)
+		decryptDuration, decryptOpMemory := measureOnce(decrypt, iterations)
+		teardown()
 
-		encryptDurations = append(encryptDurations, encryptDuration)
-		decryptDurations = append(decryptDurations, decryptDuration)
+		encryptDurations = append(encryptDurations, setupDuration+encryptDuration)
+		decryptDurations = append(decryptDurations, setupDuration+decryptDuration)
+		encryptMemory = encryptMemory.plus(setupMemory).plus(encryptOpMemory)
+		decryptMemory = decryptMemory.plus(setupMemory).plus(decryptOpMemory)
 	}
 
 	encryptResult := calculateBenchmarkResult(encryptDurations)
 	decryptResult := calculateBenchmarkResult(decryptDurations)
+	encryptResult.memory = encryptMemory
+	decryptResult.memory = decryptMemory
 
 	return encryptResult, decryptResult
 }
 
+func timeOperation(op func()) time.Duration {
+	start := time.Now()
+	op()
+	return time.Since(start)
+}
+
+// measureOnce times a single call of op; its allocations are divided by ops
+// so that the stats of several calls can be summed with MemoryStats.plus.
+func measureOnce(op func(), ops int) (time.Duration, MemoryStats) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	elapsed := timeOperation(op)
+	runtime.ReadMemStats(&after)
+	return elapsed, newMemoryStats(&before, &after, ops)
+}
+
 func calculateBenchmarkResult(durations []time.Duration) *BenchmarkResult {
 	durationsLen := len(durations)
 	if durationsLen == 0 {
@@ -279,11 +605,55 @@ func calculateBenchmarkResult(durations []time.Duration) *BenchmarkResult {
 	copy(sorted, durations)
 	slices.Sort(sorted)
 
+	mean := totalTime / time.Duration(durationsLen)
+	median := calculatePercentile(sorted, 50)
+
+	variance := 0.0
+	for _, duration := range durations {
+		diff := float64(duration - mean)
+		variance += diff * diff
+	}
+	if durationsLen > 1 {
+		variance /= float64(durationsLen - 1)
+	}
+	stddev := math.Sqrt(variance)
+	ciHalfWidth := time.Duration(tCritical95(durationsLen-1) * stddev / math.Sqrt(float64(durationsLen)))
+	if durationsLen < 2 {
+		ciHalfWidth = 0
+	}
+
+	deviations := make([]time.Duration, durationsLen)
+	for i, duration := range sorted {
+		deviations[i] = max(duration-median, median-duration)
+	}
+	slices.Sort(deviations)
+
+	// Tukey's fences
+	q1 := calculatePercentile(sorted, 25)
+	q3 := calculatePercentile(sorted, 75)
+	iqr := q3 - q1
+	outliers := 0
+	for _, duration := range sorted {
+		if duration < q1-3*iqr/2 || duration > q3+3*iqr/2 {
+			outliers++
+		}
+	}
+
 	return &BenchmarkResult{
-		mean:         totalTime / time.Duration(durationsLen),
-		median:       calculatePercentile(sorted, 50),
+		mean:         mean,
+		median:       median,
 		percentile95: calculatePercentile(sorted, 95),
 		totalTime:    totalTime,
+		stddev:       time.Duration(stddev),
+		min:          sorted[0],
+		max:          sorted[durationsLen-1],
+		mad:          calculatePercentile(deviations, 50),
+		ciLow:        mean - ciHalfWidth,
+		ciHigh:       mean + ciHalfWidth,
+		outliers:     outliers,
+		samples:      durationsLen,
+		batchSize:    1,
+		durations:    durations,
 	}
 }
 
@@ -312,97 +682,111 @@ func calculatePercentile(durations []time.Duration, percentile float64) time.Dur
 	return time.Duration(interpolated)
 }
 
-func measureKeyGen(keyNums int, keyGenFunc func() error) []time.Duration {
+func measureKeyGen(kind string, keyNums int, keyGenFunc func() (any, error)) ([]time.Duration, MemoryStats) {
 	// warm up
-	if err := keyGenFunc(); err != nil {
+	if _, err := keyGenFunc(); err != nil {
 		log.Fatalf("Error during warm-up key generation: %v\n", err)
 	}
 
 	generateKeyTimes := make([]time.Duration, 0, keyNums)
+	var keys []any
+	if keyStore != nil {
+		keys = make([]any, 0, keyNums)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
 
 	for range keyNums {
 		start := time.Now()
-		if err := keyGenFunc(); err != nil {
+		key, err := keyGenFunc()
+		if err != nil {
 			log.Fatalf("Error during key generation: %v\n", err)
 		}
 		generateKeyTimes = append(generateKeyTimes, time.Since(start))
+		if keys != nil {
+			keys = append(keys, key)
+		}
 	}
 
-	return generateKeyTimes
+	runtime.ReadMemStats(&after)
+
+	for _, key := range keys {
+		if err := keyStore.save(kind, key); err != nil {
+			log.Fatalf("Error saving %s key: %v", kind, err)
+		}
+	}
+
+	return generateKeyTimes, newMemoryStats(&before, &after, keyNums)
 }
 
-func measureTimeRSA(keyNums, bits int) []time.Duration {
-	return measureKeyGen(keyNums, func() error {
-		_, err := rsa.GenerateKey(rand.Reader, bits)
-		return err
+func measureTimeRSA(keyNums, bits int) ([]time.Duration, MemoryStats) {
+	return measureKeyGen(fmt.Sprintf("rsa%d", bits), keyNums, func() (any, error) {
+		return rsa.GenerateKey(rand.Reader, bits)
 	})
 }
 
-func measureTimeAES(keyNums, bits int) []time.Duration {
+func measureTimeAES(keyNums, bits int) ([]time.Duration, MemoryStats) {
 	keySize := bits / 8
-	return measureKeyGen(keyNums, func() error {
+	return measureKeyGen(fmt.Sprintf("aes%d", bits), keyNums, func() (any, error) {
 		key := make([]byte, keySize)
 		if _, err := rand.Read(key); err != nil {
-			return fmt.Errorf("error creating random secret key for AES: %v", err)
+			return nil, fmt.Errorf("error creating random secret key for AES: %v", err)
 		}
 		_, err := aes.NewCipher(key)
-		return err
+		return key, err
 	})
 }
 
-func measureTime3DES(keyNums, bits int) []time.Duration {
+func measureTime3DES(keyNums, bits int) ([]time.Duration, MemoryStats) {
 	keySize := bits / 8
-	return measureKeyGen(keyNums, func() error {
+	return measureKeyGen(fmt.Sprintf("des%d", bits), keyNums, func() (any, error) {
 		key := make([]byte, keySize)
 		if _, err := rand.Read(key); err != nil {
-			return fmt.Errorf("error creating random secret key for 3DES: %v", err)
+			return nil, fmt.Errorf("error creating random secret key for 3DES: %v", err)
 		}
 		_, err := des.NewTripleDESCipher(key)
-		return err
+		return key, err
 	})
 }
 
-func measureEncryptRSA(data []byte) (time.Duration, time.Duration) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		log.Fatalf("Error generating RSA key: %v", err)
-	}
+func setupEncryptRSA(bits int) (EncryptFunc, func()) {
+	privateKey := benchmarkRSAKey(bits)
 	publicKey := &privateKey.PublicKey
 
-	startEncrypt := time.Now()
-	ciphertext, err := rsa.EncryptOAEP(
-		sha256.New(),
-		rand.Reader,
-		publicKey,
-		data,
-		nil,
-	)
-	encryptDuration := time.Since(startEncrypt)
-	if err != nil {
-		log.Fatalf("Error encrypting: %v", err)
-	}
-
-	startDecrypt := time.Now()
-	_, err = rsa.DecryptOAEP(
-		sha256.New(),
-		rand.Reader,
-		privateKey,
-		ciphertext,
-		nil,
-	)
-	decryptDuration := time.Since(startDecrypt)
-	if err != nil {
-		log.Fatalf("Error decrypting: %v", err)
-	}
-
-	return encryptDuration, decryptDuration
+	return func(data []byte) (func(), func()) {
+		encrypt := func() []byte {
+			ciphertext, err := rsa.EncryptOAEP(
+				sha256.New(),
+				rand.Reader,
+				publicKey,
+				data,
+				nil,
+			)
+			if err != nil {
+				log.Fatalf("Error encrypting: %v", err)
+			}
+			return ciphertext
+		}
+		ciphertext := encrypt()
+
+		return func() { encrypt() }, func() {
+			_, err := rsa.DecryptOAEP(
+				sha256.New(),
+				rand.Reader,
+				privateKey,
+				ciphertext,
+				nil,
+			)
+			if err != nil {
+				log.Fatalf("Error decrypting: %v", err)
+			}
+		}
+	}, func() {}
 }
 
-func measureEncryptAESGCM(data []byte, keySizeBits int) (time.Duration, time.Duration) {
-	key := make([]byte, keySizeBits/8)
-	if _, err := rand.Read(key); err != nil {
-		log.Fatalf("Error generating AES key: %v", err)
-	}
+func setupEncryptAESGCM(keySizeBits int) (EncryptFunc, func()) {
+	key := benchmarkSymmetricKey(fmt.Sprintf("aes%d", keySizeBits), keySizeBits/8)
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -419,54 +803,65 @@ func measureEncryptAESGCM(data []byte, keySizeBits int) (time.Duration, time.Dur
 		log.Fatalf("Error generating nonce: %v", err)
 	}
 
-	startEncrypt := time.Now()
-	ciphertext := gcm.Seal(nil, nonce, data, nil)
-	encryptDuration := time.Since(startEncrypt)
+	return func(data []byte) (func(), func()) {
+		encrypt := func() []byte {
+			// the key is reused between operations, so every one needs a fresh nonce
+			incrementCounter(nonce)
+			return gcm.Seal(nil, nonce, data, nil)
+		}
+		ciphertext := encrypt()
+		decryptNonce := slices.Clone(nonce)
 
-	startDecrypt := time.Now()
-	_, err = gcm.Open(nil, nonce, ciphertext, nil)
-	decryptDuration := time.Since(startDecrypt)
-	if err != nil {
-		log.Fatalf("Error decrypting AES-GCM: %v", err)
-	}
+		return func() { encrypt() }, func() {
+			if _, err := gcm.Open(nil, decryptNonce, ciphertext, nil); err != nil {
+				log.Fatalf("Error decrypting AES-GCM: %v", err)
+			}
+		}
+	}, func() { clear(key) }
+}
 
-	return encryptDuration, decryptDuration
+func setupEncrypt3DESCBC(keySizeBits int) (EncryptFunc, func()) {
+	return setupEncryptCBC(fmt.Sprintf("des%d", keySizeBits), keySizeBits/8, des.NewTripleDESCipher)
 }
 
-func measureEncrypt3DESCBC(data []byte, keySizeBits int) (time.Duration, time.Duration) {
-	key := make([]byte, keySizeBits/8)
-	if _, err := rand.Read(key); err != nil {
-		log.Fatalf("Error generating 3DES key: %v", err)
-	}
+// setupEncryptCBC runs CBC with PKCS#7 padding over any cipher.Block, so
+// the stdlib ciphers and the ones in this package are measured the same way.
+func setupEncryptCBC(kind string, keySize int, newCipher func([]byte) (cipher.Block, error)) (EncryptFunc, func()) {
+	key := benchmarkSymmetricKey(kind, keySize)
 
-	block, err := des.NewTripleDESCipher(key)
+	block, err := newCipher(key)
 	if err != nil {
-		log.Fatalf("Error creating 3DES cipher: %v", err)
+		log.Fatalf("Error creating %s cipher: %v", kind, err)
 	}
 
-	iv := make([]byte, block.BlockSize())
-	if _, err := rand.Read(iv); err != nil {
-		log.Fatalf("Error generating IV: %v", err)
-	}
+	return func(data []byte) (func(), func()) {
+		// a fresh IV per message; generating it is part of every encryption
+		encrypt := func() ([]byte, []byte) {
+			iv := randomBytes(block.BlockSize())
+			paddedData := pkcs7Pad(data, block.BlockSize())
+			ciphertext := make([]byte, len(paddedData))
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, paddedData)
+			return iv, ciphertext
+		}
+		iv, ciphertext := encrypt()
 
-	startEncrypt := time.Now()
-	paddedData := pkcs7Pad(data, block.BlockSize())
-	ciphertext := make([]byte, len(paddedData))
-	mode := cipher.NewCBCEncrypter(block, iv)
-	mode.CryptBlocks(ciphertext, paddedData)
-	encryptDuration := time.Since(startEncrypt)
+		return func() { encrypt() }, func() {
+			plaintext := make([]byte, len(ciphertext))
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+			if _, err := pkcs7Unpad(plaintext, block.BlockSize()); err != nil {
+				log.Fatalf("Error removing padding: %v", err)
+			}
+		}
+	}, func() { clear(key) }
+}
 
-	startDecrypt := time.Now()
-	plaintext := make([]byte, len(ciphertext))
-	modeDecrypt := cipher.NewCBCDecrypter(block, iv)
-	modeDecrypt.CryptBlocks(plaintext, ciphertext)
-	_, err = pkcs7Unpad(plaintext, block.BlockSize())
-	decryptDuration := time.Since(startDecrypt)
-	if err != nil {
-		log.Fatalf("Error removing padding: %v", err)
+func incrementCounter(counter []byte) {
+	for i := len(counter) - 1; i >= 0; i-- {
+		counter[i]++
+		if counter[i] != 0 {
+			return
+		}
 	}
-
-	return encryptDuration, decryptDuration
 }
 
 func pkcs7Pad(data []byte, blockSize int) []byte {
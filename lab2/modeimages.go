@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	vgdraw "gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+const (
+	modeImagesDir = modesDir + "images/"
+	penguinSize   = 256
+)
+
+// drawModeImages encrypts the raw RGBA pixels of an image under every mode
+// and saves the ciphertext as an image of the same size. Large flat areas
+// repeat the same 16 or 8 byte block, which ECB maps to the same output
+// block, so the outline stays visible; the other modes turn it into noise.
+func drawModeImages(path string) error {
+	img, err := loadModeImage(path)
+	if err != nil {
+		return err
+	}
+	if err := writePNG(modeImagesDir+"original.png", img); err != nil {
+		return err
+	}
+
+	cells := make([][]image.Image, len(modeCiphers))
+	for i, c := range modeCiphers {
+		cells[i] = []image.Image{img}
+		for _, def := range modeDefinitions {
+			if !def.supports(c) {
+				cells[i] = append(cells[i], nil)
+				continue
+			}
+
+			mode, err := newModeForCipher(c, def, randomBytes(def.keys*c.keySize))
+			if err != nil {
+				return err
+			}
+			ciphertext := mode.encrypt(randomBytes(mode.ivSize()), img.Pix)
+
+			out := image.NewRGBA(img.Rect)
+			copy(out.Pix, ciphertext)
+			// keep the picture opaque, otherwise the alpha bytes hide the pattern
+			for p := 3; p < len(out.Pix); p += 4 {
+				out.Pix[p] = 0xff
+			}
+
+			file := fmt.Sprintf("%s%s_%s.png", modeImagesDir, c.file, strings.ToLower(def.name))
+			if err := writePNG(file, out); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %s\n", file)
+			cells[i] = append(cells[i], out)
+		}
+	}
+
+	return drawModeImageGrid(cells, plotDir+"ecb_penguin.png")
+}
+
+func loadModeImage(path string) (*image.RGBA, error) {
+	if path == "" {
+		return drawPenguin(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	src, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(img, img.Rect, src, src.Bounds().Min, draw.Src)
+	return img, nil
+}
+
+// drawPenguin draws the usual ECB demo subject with the vg canvas used for
+// the plots, in a few flat colours so that whole blocks repeat.
+func drawPenguin() *image.RGBA {
+	c := vgimg.NewWith(vgimg.UseWH(penguinSize, penguinSize), vgimg.UseDPI(72), vgimg.UseBackgroundColor(color.White))
+
+	orange := color.RGBA{R: 0xf5, G: 0xa6, B: 0x23, A: 0xff}
+	ellipse := func(x, y, rx, ry vg.Length, col color.Color) {
+		c.Push()
+		c.Translate(vg.Point{X: x, Y: y})
+		c.Scale(float64(rx), float64(ry))
+		var p vg.Path
+		p.Move(vg.Point{X: 1})
+		p.Arc(vg.Point{}, 1, 0, 2*math.Pi)
+		p.Close()
+		c.SetColor(col)
+		c.Fill(p)
+		c.Pop()
+	}
+
+	// feet, body, belly and head
+	ellipse(100, 28, 26, 10, orange)
+	ellipse(156, 28, 26, 10, orange)
+	ellipse(128, 110, 80, 90, color.Black)
+	ellipse(128, 100, 56, 72, color.White)
+	ellipse(128, 196, 48, 44, color.Black)
+
+	// eyes
+	ellipse(110, 206, 12, 14, color.White)
+	ellipse(146, 206, 12, 14, color.White)
+	ellipse(113, 204, 5, 6, color.Black)
+	ellipse(143, 204, 5, 6, color.Black)
+
+	var beak vg.Path
+	beak.Move(vg.Point{X: 114, Y: 186})
+	beak.Line(vg.Point{X: 142, Y: 186})
+	beak.Line(vg.Point{X: 128, Y: 168})
+	beak.Close()
+	c.SetColor(orange)
+	c.Fill(beak)
+
+	img := image.NewRGBA(image.Rect(0, 0, penguinSize, penguinSize))
+	draw.Draw(img, img.Rect, c.Image(), image.Point{}, draw.Src)
+	return img
+}
+
+// drawModeImageGrid lays the images out with one row per cipher, the
+// plaintext first and then one column per mode; nil cells stay empty.
+func drawModeImageGrid(cells [][]image.Image, filename string) error {
+	cols := len(modeDefinitions) + 1
+	plots := make([][]*plot.Plot, len(cells))
+	for i, row := range cells {
+		plots[i] = make([]*plot.Plot, cols)
+		for j, img := range row {
+			if img == nil {
+				continue
+			}
+			b := img.Bounds()
+			p := plot.New()
+			if j == 0 {
+				p.Title.Text = modeCiphers[i].name + " plaintext"
+			} else {
+				p.Title.Text = modeCiphers[i].name + "-" + modeDefinitions[j-1].name
+			}
+			p.HideAxes()
+			p.Add(plotter.NewImage(img, 0, 0, float64(b.Dx()), float64(b.Dy())))
+			plots[i][j] = p
+		}
+	}
+
+	const cellSize = 2 * vg.Inch
+	err := saveCanvas(filename, vg.Length(cols)*cellSize, vg.Length(len(cells))*cellSize, func(dc vgdraw.Canvas) {
+		tiles := vgdraw.Tiles{Rows: len(cells), Cols: cols, PadX: vg.Millimeter, PadY: vg.Millimeter}
+		canvases := plot.Align(plots, tiles, dc)
+		for i := range plots {
+			for j, p := range plots[i] {
+				if p != nil {
+					p.Draw(canvases[i][j])
+				}
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", filename)
+	return nil
+}
+
+func writePNG(filename string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
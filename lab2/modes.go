@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/xts"
+)
+
+const (
+	modesDir = "results/modes/"
+	// xtsSectorSize is the data unit XTS messages are split into; the
+	// sector number takes the place of an IV.
+	xtsSectorSize = 4096
+)
+
+// CipherMode turns a block cipher into a cipher for whole messages. A mode
+// is created per key because XTS needs a second key for its tweak.
+type CipherMode interface {
+	// ivSize is 0 for modes without an IV
+	ivSize() int
+	encrypt(iv, plaintext []byte) []byte
+	decrypt(iv, ciphertext []byte) ([]byte, error)
+}
+
+type ModeDefinition struct {
+	name string
+	// keys is the number of block cipher keys the mode consumes
+	keys int
+	// blockSize restricts the mode to ciphers with that block size; 0 means any
+	blockSize int
+	newMode   func(newCipher func([]byte) (cipher.Block, error), key []byte) (CipherMode, error)
+}
+
+type ModeCipher struct {
+	name      string
+	file      string
+	kind      string
+	keySize   int
+	blockSize int
+	newCipher func([]byte) (cipher.Block, error)
+}
+
+var (
+	modeDefinitions = []ModeDefinition{
+		{"ECB", 1, 0, withBlock(func(b cipher.Block) CipherMode { return ecbMode{b} })},
+		{"CBC", 1, 0, withBlock(func(b cipher.Block) CipherMode { return cbcMode{b} })},
+		{"CFB", 1, 0, withBlock(func(b cipher.Block) CipherMode { return cfbMode{b} })},
+		{"OFB", 1, 0, withBlock(func(b cipher.Block) CipherMode { return ofbMode{b} })},
+		{"CTR", 1, 0, withBlock(func(b cipher.Block) CipherMode { return ctrMode{b} })},
+		{"XTS", 2, 16, newXTSMode},
+	}
+
+	modeCiphers = []ModeCipher{
+		{"AES-128", "aes128", "aes128", 16, 16, aes.NewCipher},
+		{"3DES", "3des192", "des192", 24, 8, des.NewTripleDESCipher},
+	}
+)
+
+func withBlock(newMode func(cipher.Block) CipherMode) func(func([]byte) (cipher.Block, error), []byte) (CipherMode, error) {
+	return func(newCipher func([]byte) (cipher.Block, error), key []byte) (CipherMode, error) {
+		block, err := newCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return newMode(block), nil
+	}
+}
+
+// ecbMode encrypts every block on its own, so equal plaintext blocks give
+// equal ciphertext blocks. The stdlib leaves it out for that reason.
+type ecbMode struct{ block cipher.Block }
+
+func (m ecbMode) ivSize() int { return 0 }
+
+func (m ecbMode) encrypt(_, plaintext []byte) []byte {
+	bs := m.block.BlockSize()
+	out := pkcs7Pad(slices.Clip(plaintext), bs)
+	for i := 0; i < len(out); i += bs {
+		m.block.Encrypt(out[i:i+bs], out[i:i+bs])
+	}
+	return out
+}
+
+func (m ecbMode) decrypt(_, ciphertext []byte) ([]byte, error) {
+	bs := m.block.BlockSize()
+	if len(ciphertext) == 0 || len(ciphertext)%bs != 0 {
+		return nil, errors.New("ciphertext is not a whole number of blocks")
+	}
+	out := make([]byte, len(ciphertext))
+	for i := 0; i < len(out); i += bs {
+		m.block.Decrypt(out[i:i+bs], ciphertext[i:i+bs])
+	}
+	return pkcs7Unpad(out, bs)
+}
+
+type cbcMode struct{ block cipher.Block }
+
+func (m cbcMode) ivSize() int { return m.block.BlockSize() }
+
+func (m cbcMode) encrypt(iv, plaintext []byte) []byte {
+	out := pkcs7Pad(slices.Clip(plaintext), m.block.BlockSize())
+	cipher.NewCBCEncrypter(m.block, iv).CryptBlocks(out, out)
+	return out
+}
+
+func (m cbcMode) decrypt(iv, ciphertext []byte) ([]byte, error) {
+	bs := m.block.BlockSize()
+	if len(ciphertext) == 0 || len(ciphertext)%bs != 0 {
+		return nil, errors.New("ciphertext is not a whole number of blocks")
+	}
+	out := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(m.block, iv).CryptBlocks(out, ciphertext)
+	return pkcs7Unpad(out, bs)
+}
+
+// cfbMode is full-block CFB (SP 800-38A with s equal to the block size):
+// C_i = P_i ^ E(C_{i-1}). cipher.NewCFBEncrypter is deprecated, and the
+// mode is short enough to write out.
+type cfbMode struct{ block cipher.Block }
+
+func (m cfbMode) ivSize() int { return m.block.BlockSize() }
+
+func (m cfbMode) encrypt(iv, plaintext []byte) []byte {
+	return m.crypt(iv, plaintext, false)
+}
+
+func (m cfbMode) decrypt(iv, ciphertext []byte) ([]byte, error) {
+	return m.crypt(iv, ciphertext, true), nil
+}
+
+func (m cfbMode) crypt(iv, in []byte, decrypt bool) []byte {
+	bs := m.block.BlockSize()
+	out := make([]byte, len(in))
+	feedback := slices.Clone(iv)
+	keystream := make([]byte, bs)
+	for i := 0; i < len(in); i += bs {
+		end := min(i+bs, len(in))
+		m.block.Encrypt(keystream, feedback)
+		subtle.XORBytes(out[i:end], in[i:end], keystream)
+		// the next input is always the ciphertext block
+		if decrypt {
+			copy(feedback, in[i:end])
+		} else {
+			copy(feedback, out[i:end])
+		}
+	}
+	return out
+}
+
+// ofbMode iterates the cipher on the IV alone, O_i = E(O_{i-1}), so the
+// keystream does not depend on the message at all.
+type ofbMode struct{ block cipher.Block }
+
+func (m ofbMode) ivSize() int { return m.block.BlockSize() }
+
+func (m ofbMode) encrypt(iv, plaintext []byte) []byte {
+	bs := m.block.BlockSize()
+	out := make([]byte, len(plaintext))
+	keystream := slices.Clone(iv)
+	for i := 0; i < len(plaintext); i += bs {
+		m.block.Encrypt(keystream, keystream)
+		subtle.XORBytes(out[i:min(i+bs, len(plaintext))], plaintext[i:min(i+bs, len(plaintext))], keystream)
+	}
+	return out
+}
+
+func (m ofbMode) decrypt(iv, ciphertext []byte) ([]byte, error) {
+	return m.encrypt(iv, ciphertext), nil
+}
+
+type ctrMode struct{ block cipher.Block }
+
+func (m ctrMode) ivSize() int { return m.block.BlockSize() }
+
+func (m ctrMode) encrypt(iv, plaintext []byte) []byte {
+	out := make([]byte, len(plaintext))
+	cipher.NewCTR(m.block, iv).XORKeyStream(out, plaintext)
+	return out
+}
+
+func (m ctrMode) decrypt(iv, ciphertext []byte) ([]byte, error) {
+	return m.encrypt(iv, ciphertext), nil
+}
+
+// xtsMode is the disk encryption mode from IEEE 1619. x/crypto/xts has no
+// ciphertext stealing, so messages are padded to whole blocks, and it is
+// only defined for 16-byte blocks, which rules out 3DES.
+type xtsMode struct{ cipher *xts.Cipher }
+
+func newXTSMode(newCipher func([]byte) (cipher.Block, error), key []byte) (CipherMode, error) {
+	c, err := xts.NewCipher(newCipher, key)
+	if err != nil {
+		return nil, err
+	}
+	return xtsMode{c}, nil
+}
+
+func (m xtsMode) ivSize() int { return 0 }
+
+func (m xtsMode) encrypt(_, plaintext []byte) []byte {
+	out := pkcs7Pad(slices.Clip(plaintext), 16)
+	for sector, i := uint64(0), 0; i < len(out); sector, i = sector+1, i+xtsSectorSize {
+		unit := out[i:min(i+xtsSectorSize, len(out))]
+		m.cipher.Encrypt(unit, unit, sector)
+	}
+	return out
+}
+
+func (m xtsMode) decrypt(_, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || len(ciphertext)%16 != 0 {
+		return nil, errors.New("ciphertext is not a whole number of blocks")
+	}
+	out := make([]byte, len(ciphertext))
+	for sector, i := uint64(0), 0; i < len(out); sector, i = sector+1, i+xtsSectorSize {
+		end := min(i+xtsSectorSize, len(out))
+		m.cipher.Decrypt(out[i:end], ciphertext[i:end], sector)
+	}
+	return pkcs7Unpad(out, 16)
+}
+
+func (def ModeDefinition) supports(c ModeCipher) bool {
+	return def.blockSize == 0 || def.blockSize == c.blockSize
+}
+
+func newModeForCipher(c ModeCipher, def ModeDefinition, key []byte) (CipherMode, error) {
+	if !def.supports(c) {
+		return nil, fmt.Errorf("%s is only defined for %d-byte blocks", def.name, def.blockSize)
+	}
+	if len(key) != def.keys*c.keySize {
+		return nil, fmt.Errorf("%s-%s needs a %d-byte key", c.name, def.name, def.keys*c.keySize)
+	}
+	return def.newMode(c.newCipher, key)
+}
+
+func setupEncryptMode(c ModeCipher, def ModeDefinition) (EncryptFunc, func()) {
+	key := benchmarkSymmetricKey(c.kind, def.keys*c.keySize)
+	mode, err := newModeForCipher(c, def, key)
+	if err != nil {
+		log.Fatalf("Error creating %s-%s: %v", c.name, def.name, err)
+	}
+	return func(data []byte) (func(), func()) {
+		encrypt := func() ([]byte, []byte) {
+			// a fresh IV per message, as every mode except ECB and XTS requires
+			iv := randomBytes(mode.ivSize())
+			return iv, mode.encrypt(iv, data)
+		}
+		iv, ciphertext := encrypt()
+
+		return func() { encrypt() }, func() {
+			if _, err := mode.decrypt(iv, ciphertext); err != nil {
+				log.Fatalf("Error decrypting: %v", err)
+			}
+		}
+	}, func() { clear(key) }
+}
+
+func runModeBenchmarks(args []string) {
+	fs := flag.NewFlagSet("modes", flag.ExitOnError)
+	plotFormatFlag(fs)
+	image := fs.String("image", "", "PNG to encrypt for the mode images (default: a penguin drawn with gonum/plot)")
+	skipBenchmarks := fs.Bool("images-only", false, "only write the mode images")
+	fs.Parse(args)
+
+	fmt.Println("=== BLOCK CIPHER MODES ===")
+
+	if !*skipBenchmarks {
+		dataSizes := []int{128, 2 * 1024, 32 * 1024, 1024 * 1024}
+		config := defaultRunnerConfig
+		for _, c := range modeCiphers {
+			for _, def := range modeDefinitions {
+				if !def.supports(c) {
+					fmt.Printf("Skipping %s-%s: %s needs %d-byte blocks\n", c.name, def.name, def.name, def.blockSize)
+					continue
+				}
+
+				name := c.name + "-" + def.name
+				fmt.Printf("Running benchmarks for %s...\n", name)
+				config.profilePrefix = profilePrefix(modesDir, name)
+				setup := func() (EncryptFunc, func()) { return setupEncryptMode(c, def) }
+				encrypt, decrypt := runDataSizesBenchmark(dataSizes, setup, config)
+				file := fmt.Sprintf("%s_%s.csv", c.file, strings.ToLower(def.name))
+				exportAER(encrypt, modesDir+"encryption/"+file)
+				exportAER(decrypt, modesDir+"decryption/"+file)
+			}
+		}
+	}
+
+	if err := drawModeImages(*image); err != nil {
+		log.Fatalf("Error writing mode images: %v", err)
+	}
+}
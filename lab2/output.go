@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ResultsDocument struct {
+	Environment Environment  `json:"environment"`
+	Results     []*ResultSet `json:"results"`
+}
+
+// ResultSet holds one algorithm within one category (e.g. AES-128-GCM
+// encryption), with a point per swept value (bytes, number of keys, ...).
+type ResultSet struct {
+	Category  string         `json:"category"`
+	Algorithm string         `json:"algorithm"`
+	Param     string         `json:"param"`
+	Points    []*ResultPoint `json:"points"`
+}
+
+type ResultPoint struct {
+	Value int          `json:"value"`
+	Warm  *ResultStats `json:"warm"`
+	Cold  *ResultStats `json:"cold,omitempty"`
+}
+
+type ResultStats struct {
+	MeanNs         int64   `json:"mean_ns"`
+	MedianNs       int64   `json:"median_ns"`
+	P95BatchMeanNs int64   `json:"p95_batch_mean_ns"`
+	TotalNs        int64   `json:"total_ns"`
+	StddevNs       int64   `json:"stddev_ns"`
+	MinBatchMeanNs int64   `json:"min_batch_mean_ns"`
+	MaxBatchMeanNs int64   `json:"max_batch_mean_ns"`
+	MADNs          int64   `json:"mad_ns"`
+	CILowNs        int64   `json:"ci_low_ns"`
+	CIHighNs       int64   `json:"ci_high_ns"`
+	Outliers       int     `json:"outliers"`
+	Samples        int     `json:"samples"`
+	BatchSize      int     `json:"batch_size"`
+	RawBatchMeanNs []int64 `json:"raw_batch_mean_ns,omitempty"`
+
+	AllocsPerOp float64 `json:"allocs_per_op"`
+	BytesPerOp  float64 `json:"bytes_per_op"`
+	GCCycles    uint32  `json:"gc_cycles"`
+	GCPauseNs   int64   `json:"gc_pause_ns"`
+
+	durations []time.Duration
+}
+
+func newResultsDocument() *ResultsDocument {
+	return &ResultsDocument{Environment: currentEnvironment()}
+}
+
+func (d *ResultsDocument) addEncryptResults(category, algorithm string, results []*AlgorithmEncryptResult) {
+	set := &ResultSet{Category: category, Algorithm: algorithm, Param: "bytes"}
+	for _, result := range results {
+		point := &ResultPoint{Value: result.bytes, Warm: newResultStats(result.result)}
+		if result.cold != nil {
+			point.Cold = newResultStats(result.cold)
+		}
+		set.Points = append(set.Points, point)
+	}
+	d.Results = append(d.Results, set)
+}
+
+func (d *ResultsDocument) addKeyGenResults(algorithm string, results []*AlgorithmKeyGenResult) {
+	set := &ResultSet{Category: "keygen", Algorithm: algorithm, Param: "keys"}
+	for _, result := range results {
+		set.Points = append(set.Points, &ResultPoint{Value: result.keysNum, Warm: newResultStats(result.result)})
+	}
+	d.Results = append(d.Results, set)
+}
+
+func (d *ResultsDocument) addKDFResults(algorithm, param string, results []*KDFResult) {
+	set := &ResultSet{Category: "kdf", Algorithm: algorithm, Param: param}
+	for _, result := range results {
+		set.Points = append(set.Points, &ResultPoint{Value: result.param, Warm: newResultStats(result.result)})
+	}
+	d.Results = append(d.Results, set)
+}
+
+// addKeyFormatResult adds a set per key and format whose single point is
+// the encoded size.
+func (d *ResultsDocument) addKeyFormatResult(category string, r *KeyFormatResult, result *BenchmarkResult) {
+	set := &ResultSet{Category: category, Algorithm: r.key + " " + strings.ToUpper(r.format), Param: "encoded bytes"}
+	set.Points = append(set.Points, &ResultPoint{Value: r.bytes, Warm: newResultStats(result)})
+	d.Results = append(d.Results, set)
+}
+
+func (d *ResultsDocument) sort() {
+	slices.SortFunc(d.Results, func(a, b *ResultSet) int {
+		return cmp.Or(cmp.Compare(a.Category, b.Category), cmp.Compare(a.Algorithm, b.Algorithm), cmp.Compare(a.Param, b.Param))
+	})
+}
+
+func newResultStats(result *BenchmarkResult) *ResultStats {
+	stats := &ResultStats{
+		MeanNs:         result.mean.Nanoseconds(),
+		MedianNs:       result.median.Nanoseconds(),
+		P95BatchMeanNs: result.percentile95.Nanoseconds(),
+		TotalNs:        result.totalTime.Nanoseconds(),
+		StddevNs:       result.stddev.Nanoseconds(),
+		MinBatchMeanNs: result.min.Nanoseconds(),
+		MaxBatchMeanNs: result.max.Nanoseconds(),
+		MADNs:          result.mad.Nanoseconds(),
+		CILowNs:        result.ciLow.Nanoseconds(),
+		CIHighNs:       result.ciHigh.Nanoseconds(),
+		Outliers:       result.outliers,
+		Samples:        result.samples,
+		BatchSize:      result.batchSize,
+
+		AllocsPerOp: result.memory.allocsPerOp,
+		BytesPerOp:  result.memory.bytesPerOp,
+		GCCycles:    result.memory.gcCycles,
+		GCPauseNs:   result.memory.gcPause.Nanoseconds(),
+
+		durations: result.durations,
+	}
+	if exportRawSamples {
+		for _, duration := range result.durations {
+			stats.RawBatchMeanNs = append(stats.RawBatchMeanNs, duration.Nanoseconds())
+		}
+	}
+	return stats
+}
+
+func exportJSON(filename string, doc *ResultsDocument) {
+	doc.sort()
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Fatalf("error encoding json: %v", err)
+	}
+
+	writeResultFile(filename, append(data, '\n'))
+}
+
+// exportBenchstat writes the results in the Go benchmark text format, one
+// line per sample, so two runs can be compared with
+// `benchstat old.txt new.txt`.
+func exportBenchstat(filename string, doc *ResultsDocument) {
+	doc.sort()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "goos: %s\n", doc.Environment.GOOS)
+	fmt.Fprintf(&b, "goarch: %s\n", doc.Environment.GOARCH)
+	fmt.Fprintf(&b, "pkg: lab2\n")
+	fmt.Fprintf(&b, "cpu: %s\n", doc.Environment.CPUModel)
+	for _, field := range doc.Environment.fields() {
+		if field[0] != "goos" && field[0] != "goarch" && field[0] != "cpu" {
+			fmt.Fprintf(&b, "%s: %s\n", field[0], field[1])
+		}
+	}
+
+	procs := ""
+	if doc.Environment.GOMAXPROCS > 1 {
+		procs = fmt.Sprintf("-%d", doc.Environment.GOMAXPROCS)
+	}
+
+	for _, set := range doc.Results {
+		for _, point := range set.Points {
+			name := fmt.Sprintf("Benchmark%s/%s/%s=%d%s",
+				benchstatCategory(set.Category), benchstatName(set.Algorithm), benchstatParam(set.Param), point.Value, procs)
+
+			samples := point.Warm.durations
+			if len(samples) == 0 {
+				samples = []time.Duration{time.Duration(point.Warm.MeanNs)}
+			}
+
+			for _, sample := range samples {
+				fmt.Fprintf(&b, "%s\t%d\t%d ns/op", name, point.Warm.BatchSize, sample.Nanoseconds())
+				if set.Param == "bytes" && sample > 0 {
+					fmt.Fprintf(&b, "\t%.2f MB/s", float64(point.Value)/sample.Seconds()/decimalMB)
+				}
+				fmt.Fprintf(&b, "\t%.0f B/op\t%.0f allocs/op", point.Warm.BytesPerOp, point.Warm.AllocsPerOp)
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	writeResultFile(filename, []byte(b.String()))
+}
+
+func benchstatCategory(category string) string {
+	words := strings.FieldsFunc(category, func(r rune) bool { return r == '_' || r == '-' || r == ' ' })
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, "")
+}
+
+func benchstatParam(param string) string {
+	if param == "bytes" {
+		return "size"
+	}
+	return benchstatName(param)
+}
+
+// benchstatName strips characters that are not allowed in benchmark names,
+// e.g. "scrypt (r=8, p=1)" becomes "scrypt_r8_p1". benchstat would read an
+// "=" as a key=value pair.
+func benchstatName(name string) string {
+	return strings.NewReplacer(" ", "_", ",", "", "(", "", ")", "", "/", "_", "=", "").Replace(name)
+}
+
+func writeResultFile(filename string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		log.Fatalf("error during directory creation: %v", err)
+	}
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		log.Fatalf("error writing %s: %v", filename, err)
+	}
+}
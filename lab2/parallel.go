@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const parallelDir = "results/parallel/"
+
+// ParallelResult holds throughputs in decimal MB/s, like benchstat, and the
+// scaling efficiency against a single worker.
+type ParallelResult struct {
+	bytes             int
+	workers           int
+	encryptOps        int
+	decryptOps        int
+	encryptMBs        float64
+	decryptMBs        float64
+	encryptEfficiency float64
+	decryptEfficiency float64
+}
+
+func runParallelBenchmarks(args []string) {
+	fs := flag.NewFlagSet("parallel", flag.ExitOnError)
+	plotFormatFlag(fs)
+	duration := fs.Duration("duration", 500*time.Millisecond, "how long every operation is measured for each worker count")
+	fs.Parse(args)
+
+	maxWorkers := runtime.NumCPU()
+
+	fmt.Println("=== PARALLEL THROUGHPUT BENCHMARKS ===")
+	fmt.Printf("Workers: 1..%d (GOMAXPROCS=%d)\n", maxWorkers, runtime.GOMAXPROCS(0))
+
+	for algoName, algo := range encryptionAlgorithms {
+		fmt.Printf("Running benchmarks for %s...\n", algoName)
+
+		// every worker has its own keys and cipher contexts; the first n of
+		// them are used for n workers
+		measureFuncs := make([]EncryptFunc, maxWorkers)
+		teardowns := make([]func(), maxWorkers)
+		for w := range maxWorkers {
+			measureFuncs[w], teardowns[w] = algo.setup()
+		}
+
+		results := make([]*ParallelResult, 0, len(algo.dataSizes)*maxWorkers)
+		for _, bytes := range algo.dataSizes {
+			data := make([]byte, bytes)
+			if _, err := rand.Read(data); err != nil {
+				log.Fatalf("Error generating random data: %v", err)
+			}
+
+			encrypts := make([]func(), maxWorkers)
+			decrypts := make([]func(), maxWorkers)
+			for w, measureFunc := range measureFuncs {
+				encrypts[w], decrypts[w] = measureFunc(data)
+				for range warmUpIterations {
+					encrypts[w]()
+					decrypts[w]()
+				}
+			}
+
+			var single *ParallelResult
+			for workers := 1; workers <= maxWorkers; workers++ {
+				result := measureParallel(encrypts[:workers], decrypts[:workers], bytes, *duration)
+				if single == nil {
+					single = result
+				}
+				result.encryptEfficiency = scalingEfficiency(result.encryptMBs, single.encryptMBs, workers)
+				result.decryptEfficiency = scalingEfficiency(result.decryptMBs, single.decryptMBs, workers)
+
+				fmt.Printf("  %d bytes, %d workers: %.2f MB/s encrypt, %.2f MB/s decrypt, efficiency %.0f%% / %.0f%%\n",
+					bytes, workers, result.encryptMBs, result.decryptMBs, result.encryptEfficiency*100, result.decryptEfficiency*100)
+				results = append(results, result)
+			}
+		}
+
+		for _, teardown := range teardowns {
+			teardown()
+		}
+		exportParallel(results, parallelDir+algo.file)
+	}
+}
+
+// measureParallel runs the encryptions and then the decryptions, one
+// goroutine per operation. The aggregate throughput of a phase is every byte
+// processed divided by its wall-clock window, which lasts from starting the
+// workers until the last of them finishes its final operation.
+func measureParallel(encrypts, decrypts []func(), bytes int, duration time.Duration) *ParallelResult {
+	result := &ParallelResult{bytes: bytes, workers: len(encrypts)}
+
+	var encryptWindow, decryptWindow time.Duration
+	result.encryptOps, encryptWindow = runParallelPhase(encrypts, duration)
+	result.decryptOps, decryptWindow = runParallelPhase(decrypts, duration)
+
+	result.encryptMBs = float64(result.encryptOps*bytes) / decimalMB / encryptWindow.Seconds()
+	result.decryptMBs = float64(result.decryptOps*bytes) / decimalMB / decryptWindow.Seconds()
+	return result
+}
+
+func scalingEfficiency(throughput, single float64, workers int) float64 {
+	if single <= 0 {
+		return 0
+	}
+	return throughput / (float64(workers) * single)
+}
+
+func runParallelPhase(ops []func(), duration time.Duration) (int, time.Duration) {
+	counts := make([]int, len(ops))
+	var done sync.WaitGroup
+	begin := make(chan struct{})
+	var start time.Time
+
+	for w, op := range ops {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			<-begin
+			for time.Since(start) < duration {
+				op()
+				counts[w]++
+			}
+		}()
+	}
+
+	start = time.Now()
+	close(begin)
+	done.Wait()
+	window := time.Since(start)
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total, window
+}
+
+func exportParallel(results []*ParallelResult, filepath string) {
+	records := [][]string{{"Bytes", "Workers", "Encrypt ops", "Decrypt ops", "Encrypt throughput (MB/s, 10^6 B)", "Decrypt throughput (MB/s, 10^6 B)",
+		"Encrypt scaling efficiency", "Decrypt scaling efficiency"}}
+	for _, result := range results {
+		records = append(records, []string{
+			fmt.Sprint(result.bytes),
+			fmt.Sprint(result.workers),
+			fmt.Sprint(result.encryptOps),
+			fmt.Sprint(result.decryptOps),
+			fmt.Sprintf("%.4f", result.encryptMBs),
+			fmt.Sprintf("%.4f", result.decryptMBs),
+			fmt.Sprintf("%.4f", result.encryptEfficiency),
+			fmt.Sprintf("%.4f", result.decryptEfficiency),
+		})
+	}
+	exportToCSV(filepath, records)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"image/color"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+var markerShapes = map[string]draw.GlyphDrawer{
+	"circle":   draw.CircleGlyph{},
+	"ring":     draw.RingGlyph{},
+	"square":   draw.SquareGlyph{},
+	"box":      draw.BoxGlyph{},
+	"triangle": draw.TriangleGlyph{},
+	"pyramid":  draw.PyramidGlyph{},
+	"plus":     draw.PlusGlyph{},
+	"cross":    draw.CrossGlyph{},
+}
+
+// positivePoints drops the points a log axis cannot show, such as a zero
+// rate, and keeps the error bars from reaching zero.
+func positivePoints(series SeriesData, xLog, yLog bool, title string) SeriesData {
+	kept := SeriesData{Name: series.Name, Marker: series.Marker}
+	for i, pt := range series.Points {
+		if (xLog && pt.X <= 0) || (yLog && pt.Y <= 0) {
+			continue
+		}
+		kept.Points = append(kept.Points, pt)
+		if series.Errors != nil {
+			e := series.Errors[i]
+			if yLog && e.Low >= pt.Y {
+				e.Low = 0
+			}
+			kept.Errors = append(kept.Errors, e)
+		}
+	}
+	if dropped := len(series.Points) - len(kept.Points); dropped > 0 {
+		log.Printf("  [!] Dropping %d non-positive points of '%s' from the log axis of '%s'", dropped, series.Name, title)
+	}
+	return kept
+}
+
+func useLogScale(axis *plot.Axis) {
+	if axis.Min <= 0 || axis.Min > axis.Max {
+		// nothing left to draw; a linear axis at least does not panic
+		return
+	}
+	if axis.Min == axis.Max {
+		axis.Min, axis.Max = axis.Min/2, axis.Max*2
+	}
+	axis.Scale = plot.LogScale{}
+	axis.Tick.Marker = logTicks{}
+}
+
+// logTicks labels every power of ten in range, including those below one
+// that plot.LogTicks skips, so sizes in KiB starting at 0.125 get a label.
+type logTicks struct{}
+
+func (logTicks) Ticks(min, max float64) []plot.Tick {
+	var ticks []plot.Tick
+	for exp := math.Floor(math.Log10(min)); exp <= math.Ceil(math.Log10(max)); exp++ {
+		decade := math.Pow(10, exp)
+		for i := 1; i < 10; i++ {
+			v := decade * float64(i)
+			if v < min || v > max {
+				continue
+			}
+			tick := plot.Tick{Value: v}
+			if i == 1 {
+				tick.Label = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			ticks = append(ticks, tick)
+		}
+	}
+	return ticks
+}
+
+// addDistributions draws one box or violin per series and x value. The x
+// values become evenly spaced labels, with the series side by side.
+func addDistributions(p *plot.Plot, kind string, data []SeriesData) error {
+	var xs []float64
+	for _, series := range data {
+		for _, pt := range series.Points {
+			if !slices.Contains(xs, pt.X) {
+				xs = append(xs, pt.X)
+			}
+		}
+	}
+	slices.Sort(xs)
+
+	slot := 0.8 / float64(len(data))
+	width := plotSize / vg.Length(2*len(xs)*len(data))
+	for i, series := range data {
+		groups := make([]plotter.Values, len(xs))
+		for _, pt := range series.Points {
+			j := slices.Index(xs, pt.X)
+			groups[j] = append(groups[j], pt.Y)
+		}
+
+		offset := (float64(i) - float64(len(data)-1)/2) * slot
+		for j, values := range groups {
+			if len(values) == 0 {
+				continue
+			}
+			location := float64(j) + offset
+			if kind == "violin" {
+				p.Add(&violinPlot{location: location, width: width, values: values, color: plotutil.Color(i)})
+				continue
+			}
+			box, err := plotter.NewBoxPlot(width, location, values)
+			if err != nil {
+				return err
+			}
+			box.FillColor = plotutil.Color(i)
+			p.Add(box)
+		}
+
+		if series.Name != "" {
+			p.Legend.Add(series.Name, colorThumbnail{plotutil.Color(i)})
+		}
+	}
+
+	labels := make([]string, len(xs))
+	for i, x := range xs {
+		labels[i] = strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	p.NominalX(labels...)
+	return nil
+}
+
+// violinPlot draws a Gaussian kernel density estimate of the values,
+// mirrored around the location and cut at the smallest and largest value.
+// The density is estimated on the canvas, after the y scale is applied, so
+// that the shape matches what a log axis shows.
+type violinPlot struct {
+	location float64
+	width    vg.Length
+	values   plotter.Values
+	color    color.Color
+}
+
+func (v *violinPlot) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, trY := plt.Transforms(&c)
+	x := trX(v.location)
+
+	ys := make([]float64, len(v.values))
+	var mean float64
+	for i, value := range v.values {
+		ys[i] = float64(trY(value))
+		mean += ys[i]
+	}
+	slices.Sort(ys)
+	mean /= float64(len(ys))
+	var variance float64
+	for _, y := range ys {
+		variance += (y - mean) * (y - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(ys)))
+
+	outline := draw.LineStyle{Color: color.Black, Width: vg.Points(0.5)}
+	median := vg.Length(ys[len(ys)/2])
+	if stddev == 0 {
+		c.StrokeLine2(outline, x-v.width/2, median, x+v.width/2, median)
+		return
+	}
+
+	// Silverman's rule of thumb
+	bandwidth := 1.06 * stddev * math.Pow(float64(len(ys)), -0.2)
+	const steps = 64
+	low, high := ys[0], ys[len(ys)-1]
+	density := make([]float64, steps+1)
+	var peak float64
+	for i := range density {
+		y := low + (high-low)*float64(i)/steps
+		for _, sample := range ys {
+			u := (y - sample) / bandwidth
+			density[i] += math.Exp(-u * u / 2)
+		}
+		peak = math.Max(peak, density[i])
+	}
+
+	shape := make([]vg.Point, 0, 2*len(density))
+	for i, d := range density {
+		y := vg.Length(low + (high-low)*float64(i)/steps)
+		shape = append(shape, vg.Point{X: x + v.width/2*vg.Length(d/peak), Y: y})
+	}
+	for i := len(density) - 1; i >= 0; i-- {
+		y := vg.Length(low + (high-low)*float64(i)/steps)
+		shape = append(shape, vg.Point{X: x - v.width/2*vg.Length(density[i]/peak), Y: y})
+	}
+	c.FillPolygon(v.color, shape)
+	c.StrokeLines(outline, append(shape, shape[0]))
+	c.StrokeLine2(outline, x-v.width/4, median, x+v.width/4, median)
+}
+
+func (v *violinPlot) DataRange() (xmin, xmax, ymin, ymax float64) {
+	ymin, ymax = plotter.Range(v.values)
+	return v.location, v.location, ymin, ymax
+}
+
+// GlyphBoxes reserves half the width on either side of the location, like
+// plotter.BoxPlot does, so the outermost violins are not cut off.
+func (v *violinPlot) GlyphBoxes(plt *plot.Plot) []plot.GlyphBox {
+	ymin, ymax := plotter.Range(v.values)
+	return []plot.GlyphBox{{
+		X: plt.X.Norm(v.location),
+		Y: plt.Y.Norm((ymin + ymax) / 2),
+		Rectangle: vg.Rectangle{
+			Min: vg.Point{X: -v.width / 2},
+			Max: vg.Point{X: v.width / 2},
+		},
+	}}
+}
+
+type colorThumbnail struct {
+	color color.Color
+}
+
+func (t colorThumbnail) Thumbnail(c *draw.Canvas) {
+	c.FillPolygon(t.color, []vg.Point{
+		{X: c.Min.X, Y: c.Min.Y},
+		{X: c.Min.X, Y: c.Max.Y},
+		{X: c.Max.X, Y: c.Max.Y},
+		{X: c.Max.X, Y: c.Min.Y},
+	})
+}
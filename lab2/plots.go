@@ -1,35 +1,44 @@
 package main
 
 import (
+	"bytes"
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
 )
 
 const (
-	plotDir    = "results/plots/"
-	keygenDir  = "results/keygen/"
-	encryptDir = "results/encryption/"
-	decryptDir = "results/decryption/"
-	plotSize   = 8 * vg.Inch
-
-	mbDivider    = 1024 * 1024
-	kbDivider    = 1024
-	pointsLimit  = 8
-	pointsLimit4 = 4
+	plotDir  = "results/plots/"
+	plotSize = 8 * vg.Inch
+
+	mbDivider = 1024 * 1024
+	// decimalMB is the megabyte of MB/s in benchstat and testing.B output
+	decimalMB = 1e6
 )
 
+// plotFormats are the file types every plot is saved as; the extension of
+// the configured path is replaced for each.
+var plotFormats = []string{"png"}
+
+var supportedPlotFormats = []string{"png", "svg", "pdf"}
+
 type PlotSeries struct {
 	Name    string
-	GetData func() (plotter.XYs, error)
+	Marker  string
+	GetData func() (SeriesData, error)
 }
 
 type PlotConfig struct {
@@ -37,289 +46,248 @@ type PlotConfig struct {
 	XLabel   string
 	YLabel   string
 	Filepath string
+	Kind     string
+	XLog     bool
+	YLog     bool
 	Series   []PlotSeries
 }
 
-func drawAndSavePlot(title, xLabel, yLabel, filepath string, args ...interface{}) {
+// SeriesData is a series read and ready to draw; Errors is nil without
+// error bars and Environment is the summary of where it was measured.
+type SeriesData struct {
+	Name        string
+	Marker      string
+	Points      plotter.XYs
+	Errors      plotter.YErrors
+	Environment string
+}
+
+func drawAndSavePlot(config PlotConfig, data []SeriesData) {
 	p := plot.New()
-	p.Title.Text = title
-	p.X.Label.Text = xLabel
-	p.Y.Label.Text = yLabel
+	p.Title.Text = config.Title
+	p.X.Label.Text = config.XLabel
+	p.Y.Label.Text = config.YLabel
 
-	if len(args) == 0 {
-		log.Printf("  [!] Skipping plot %s: no data series provided", title)
+	if len(data) == 0 {
+		log.Printf("  [!] Skipping plot %s: no data series provided", config.Title)
 		return
 	}
 
-	err := plotutil.AddLinePoints(p, args...)
+	distribution := config.Kind == "box" || config.Kind == "violin"
+	xLog := config.XLog && !distribution
+	if xLog || config.YLog {
+		for i := range data {
+			data[i] = positivePoints(data[i], xLog, config.YLog, config.Title)
+		}
+	}
+
+	var err error
+	if distribution {
+		err = addDistributions(p, config.Kind, data)
+	} else {
+		err = addLinePoints(p, data)
+	}
 	if err != nil {
-		log.Printf("  [!] Error adding line points for %s: %v", title, err)
+		log.Printf("  [!] Error adding series for %s: %v", config.Title, err)
 	}
 
-	if err := p.Save(plotSize, plotSize, filepath); err != nil {
-		log.Printf("  [!] ERROR saving plot %s: %v", filepath, err)
+	if xLog {
+		useLogScale(&p.X)
+	}
+	if config.YLog {
+		useLogScale(&p.Y)
 	}
+
+	savePlot(p, config.Filepath)
 }
 
-func drawAll() {
-	plots := []PlotConfig{
-		{
-			Title: "Asymmetric algorithms comparison", XLabel: "Number of keys", YLabel: "Total Time (s)",
-			Filepath: plotDir + "keygen_asymmetric.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "rsa2048.csv") }},
-				{"RSA 3072", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "rsa3072.csv") }},
-			},
-		},
-		{
-			Title: "Symmetric algorithms comparison", XLabel: "Number of keys", YLabel: "Total Time (s)",
-			Filepath: plotDir + "keygen_symmetric.png",
-			Series: []PlotSeries{
-				{"AES 128", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "aes128.csv") }},
-				{"AES 256", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "aes256.csv") }},
-				{"DES 192", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "des192.csv") }},
-			},
-		},
-		{
-			Title: "All algorithms", XLabel: "Number of keys", YLabel: "Total Time (s)",
-			Filepath: plotDir + "keygen_all.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "rsa2048.csv") }},
-				{"RSA 3072", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "rsa3072.csv") }},
-				{"AES 128", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "aes128.csv") }},
-				{"AES 256", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "aes256.csv") }},
-				{"DES 192", func() (plotter.XYs, error) { return getPointsKeyGen(keygenDir + "des192.csv") }},
-			},
-		},
-		{
-			Title: "Encryption - All algorithms", XLabel: "Size (MBs)", YLabel: "Mean Time (ms)",
-			Filepath: plotDir + "encryption_all.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"rsa2048.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-				{"AES 128", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"aes128.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-				{"AES 256", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"aes256.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-				{"DES 192", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"3des192.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-			},
-		},
-		{
-			Title: "Encryption all algorithms (4 points)", XLabel: "Size (KBs)", YLabel: "Mean Time (μs)",
-			Filepath: plotDir + "encryption_all_4points.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"rsa2048.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-				{"AES 128", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"aes128.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-				{"AES 256", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"aes256.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-				{"DES 192", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(encryptDir+"3des192.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-			},
-		},
-		{
-			Title: "Decryption - All algorithms", XLabel: "Size (MBs)", YLabel: "Mean Time (ms)",
-			Filepath: plotDir + "decryption_all.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"rsa2048.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-				{"AES 128", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"aes128.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-				{"AES 256", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"aes256.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-				{"DES 192", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"3des192.csv", pointsLimit, mbDivider, time.Millisecond)
-				}},
-			},
-		},
-		{
-			Title: "Decryption all algorithms (4 points)", XLabel: "Size (KBs)", YLabel: "Mean Time (μs)",
-			Filepath: plotDir + "decryption_all_4points.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"rsa2048.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-				{"AES 128", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"aes128.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-				{"AES 256", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"aes256.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-				{"DES 192", func() (plotter.XYs, error) {
-					return getPointsEncryptionTime(decryptDir+"3des192.csv", pointsLimit4, kbDivider, time.Microsecond)
-				}},
-			},
-		},
-		{
-			Title: "Throughput encryption all algorithms", XLabel: "Size (MBs)", YLabel: "Throughput (MB/s)",
-			Filepath: plotDir + "encryption_all_throughput.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(encryptDir + "rsa2048.csv") }},
-				{"AES 128", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(encryptDir + "aes128.csv") }},
-				{"AES 256", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(encryptDir + "aes256.csv") }},
-				{"DES 192", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(encryptDir + "3des192.csv") }},
-			},
-		},
-		{
-			Title: "Throughput decryption all algorithms", XLabel: "Size (MBs)", YLabel: "Throughput (MB/s)",
-			Filepath: plotDir + "decryption_all_throughput.png",
-			Series: []PlotSeries{
-				{"RSA 2048", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(decryptDir + "rsa2048.csv") }},
-				{"AES 128", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(decryptDir + "aes128.csv") }},
-				{"AES 256", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(decryptDir + "aes256.csv") }},
-				{"DES 192", func() (plotter.XYs, error) { return getPointsEncryptionThroughput(decryptDir + "3des192.csv") }},
-			},
-		},
+func savePlot(p *plot.Plot, filename string) {
+	if err := saveCanvas(filename, plotSize, plotSize, p.Draw); err != nil {
+		log.Printf("  [!] ERROR saving plot: %v", err)
 	}
+}
 
-	for _, config := range plots {
-		log.Printf("Drawing plot: %s", config.Title)
-
-		args := make([]interface{}, 0, len(config.Series)*2)
+// saveCanvas draws once for each of plotFormats, replacing the extension of
+// filename, so that figures made of several plots honor -format as well.
+func saveCanvas(filename string, width, height vg.Length, drawFunc func(draw.Canvas)) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
 
-		for _, series := range config.Series {
-			data, err := series.GetData()
-			if err != nil {
-				log.Printf("  [!] Skipping series '%s' for plot '%s': %v", series.Name, config.Title, err)
-				continue
-			}
-			args = append(args, series.Name, data)
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, format := range plotFormats {
+		c, err := draw.NewFormattedCanvas(width, height, format)
+		if err != nil {
+			return err
 		}
+		drawFunc(draw.New(c))
 
-		drawAndSavePlot(config.Title, config.XLabel, config.YLabel, config.Filepath, args...)
+		f, err := os.Create(base + "." + format)
+		if err != nil {
+			return err
+		}
+		if _, err := c.WriteTo(f); err != nil {
+			f.Close()
+			return fmt.Errorf("writing %s.%s: %w", base, format, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func getPointsEncryptionTime(filepath string, pointsLimit int, xDivider float64, yUnit time.Duration) (plotter.XYs, error) {
-	pts := make(plotter.XYs, 0)
-	res, err := readCsvFile(filepath)
-	if err != nil {
-		return nil, err
-	}
+// plotFormatFlag adds -format to the subcommands that end up drawing plots.
+func plotFormatFlag(fs *flag.FlagSet) {
+	fs.Func("format", "comma-separated plot file types: "+strings.Join(supportedPlotFormats, ", ")+" (default png)", setPlotFormats)
+}
 
-	for i, row := range res {
-		if i+1 > pointsLimit {
-			break
+func setPlotFormats(value string) error {
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if !slices.Contains(supportedPlotFormats, format) {
+			return fmt.Errorf("unsupported plot format %q, expected one of %s", format, strings.Join(supportedPlotFormats, ", "))
 		}
-		if len(row) < 2 {
-			return nil, fmt.Errorf("invalid row in %s: expected at least 2 columns, got %d", filepath, len(row))
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
 		}
+	}
+	plotFormats = formats
+	return nil
+}
 
-		point := plotter.XY{}
-
-		totalBytes, err := strconv.ParseFloat(row[0], 64)
+// addLinePoints does what plotutil.AddLinePoints does, plus the per-series
+// markers and error bars.
+func addLinePoints(p *plot.Plot, data []SeriesData) error {
+	for i, series := range data {
+		l, s, err := plotter.NewLinePoints(series.Points)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing bytes in %s (row %d): %w", filepath, i, err)
+			return err
+		}
+		l.Color = plotutil.Color(i)
+		l.Dashes = plotutil.Dashes(i)
+		s.Color = plotutil.Color(i)
+		s.Shape = plotutil.Shape(i)
+		p.Add(l)
+		thumbnails := []plot.Thumbnailer{l}
+		if series.Marker != "none" {
+			if shape, ok := markerShapes[series.Marker]; ok {
+				s.Shape = shape
+			}
+			p.Add(s)
+			thumbnails = append(thumbnails, s)
 		}
 
-		mean, err := time.ParseDuration(row[1])
-		if err != nil {
-			return nil, fmt.Errorf("error parsing mean in %s (row %d): %w", filepath, i, err)
+		if series.Errors != nil {
+			bars, err := plotter.NewYErrorBars(errorPoints{series.Points, series.Errors})
+			if err != nil {
+				return err
+			}
+			bars.LineStyle.Color = plotutil.Color(i)
+			p.Add(bars)
 		}
 
-		point.X = totalBytes / xDivider
-		point.Y = float64(mean / yUnit)
-		pts = append(pts, point)
+		if series.Name != "" {
+			p.Legend.Add(series.Name, thumbnails...)
+		}
 	}
-	return pts, nil
+	return nil
+}
+
+type errorPoints struct {
+	plotter.XYs
+	plotter.YErrors
 }
 
-func getPointsKeyGen(filepath string) (plotter.XYs, error) {
-	pts := make(plotter.XYs, 0)
-	res, err := readCsvFile(filepath)
+func drawAll() {
+	specs, err := loadPlotSpecs(plotSpecFile)
 	if err != nil {
-		return nil, err
+		log.Printf("  [!] ERROR loading plot definitions: %v", err)
+		return
 	}
 
-	for i, row := range res {
-		if len(row) < 5 {
-			return nil, fmt.Errorf("invalid row in %s: expected at least 5 columns, got %d", filepath, len(row))
+	if err := os.MkdirAll(plotDir, 0o755); err != nil {
+		log.Printf("  [!] ERROR creating plot directory %s: %v", plotDir, err)
+		return
+	}
+
+	for _, spec := range specs {
+		config := spec.plotConfig()
+		log.Printf("Drawing plot: %s", config.Title)
+
+		data := make([]SeriesData, 0, len(config.Series))
+		for _, series := range config.Series {
+			d, err := series.GetData()
+			if err != nil {
+				log.Printf("  [!] Skipping series '%s' for plot '%s': %v", series.Name, config.Title, err)
+				continue
+			}
+			d.Name, d.Marker = series.Name, series.Marker
+			data = append(data, d)
 		}
-		point := plotter.XY{}
 
-		keysNum, err := strconv.ParseFloat(row[0], 64)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing keys num in %s (row %d): %w", filepath, i, err)
+		if subtitle := plotSubtitle(config.Title, data); subtitle != "" {
+			config.Title += "\n" + subtitle
 		}
 
-		totalTime, err := time.ParseDuration(row[4])
-		if err != nil {
-			return nil, fmt.Errorf("error parsing total time in %s (row %d): %w", filepath, i, err)
+		drawAndSavePlot(config, data)
+	}
+}
+
+// plotSubtitle names the environment the series were measured in, so that
+// series from different machines are never silently drawn together.
+func plotSubtitle(title string, data []SeriesData) string {
+	environments := map[string]bool{}
+	for _, series := range data {
+		if series.Environment != "" {
+			environments[series.Environment] = true
 		}
+	}
 
-		point.X = keysNum
-		point.Y = totalTime.Seconds()
-		pts = append(pts, point)
+	switch len(environments) {
+	case 0:
+		return ""
+	case 1:
+		for summary := range environments {
+			return summary
+		}
 	}
-	return pts, nil
+
+	log.Printf("  [!] Plot '%s' mixes results from %d different environments", title, len(environments))
+	return fmt.Sprintf("WARNING: series from %d different environments", len(environments))
 }
 
-func readCsvFile(filepath string) ([][]string, error) {
-	f, err := os.Open(filepath)
+// readCsvTable returns the header, the rows and the environment of a result
+// file; the environment is nil when the file does not record one.
+func readCsvTable(filepath string) ([]string, [][]string, *Environment, error) {
+	data, err := os.ReadFile(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("error while opening a file %s: %w", filepath, err)
+		return nil, nil, nil, fmt.Errorf("error while opening a file %s: %w", filepath, err)
 	}
-	defer f.Close()
 
-	csvReader := csv.NewReader(f)
+	csvReader := csv.NewReader(bytes.NewReader(data))
 	records, err := csvReader.ReadAll()
 	if err != nil {
-		return nil, fmt.Errorf("error while reading csv file %s: %w", filepath, err)
+		return nil, nil, nil, fmt.Errorf("error while reading csv file %s: %w", filepath, err)
 	}
 
 	if len(records) < 1 {
-		return nil, fmt.Errorf("empty csv file: %s", filepath)
+		return nil, nil, nil, fmt.Errorf("empty csv file: %s", filepath)
 	}
-	return records[1:], nil
-}
 
-func getPointsEncryptionThroughput(filepath string) (plotter.XYs, error) {
-	pts := make(plotter.XYs, 0)
-	res, err := readCsvFile(filepath)
+	env, err := readEnvironment(filepath)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
+	return records[0], records[1:], env, nil
+}
 
-	for i, row := range res {
-		if len(row) < 2 {
-			return nil, fmt.Errorf("invalid row in %s: expected at least 2 columns, got %d", filepath, len(row))
-		}
-
-		point := plotter.XY{}
-
-		totalBytes, err := strconv.ParseFloat(row[0], 64)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing bytes in %s (row %d): %w", filepath, i, err)
-		}
-
-		mean, err := time.ParseDuration(row[1])
-		if err != nil {
-			return nil, fmt.Errorf("error parsing mean in %s (row %d): %w", filepath, i, err)
-		}
-
-		sizeMB := totalBytes / mbDivider
-		timeS := mean.Seconds()
-
-		point.X = sizeMB
-		if timeS > 0 {
-			point.Y = sizeMB / timeS
-		} else {
-			point.Y = 0
-		}
-		pts = append(pts, point)
+// parseDurationColumn reads integer nanoseconds and falls back to Go duration
+// strings ("1.234ms") used by result files exported before the ns columns.
+func parseDurationColumn(value string) (time.Duration, error) {
+	if ns, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(ns), nil
 	}
-	return pts, nil
+	return time.ParseDuration(value)
 }
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// plotSpecFile is read by drawAll; the plots subcommand can point it at
+// another file.
+var plotSpecFile = "plots.json"
+
+type PlotSpecFile struct {
+	Plots []PlotSpec `json:"plots"`
+}
+
+// PlotSpec describes one plot. File is relative to results/plots/; its
+// extension is replaced by each of plotFormats.
+type PlotSpec struct {
+	Title  string `json:"title"`
+	XLabel string `json:"xLabel"`
+	YLabel string `json:"yLabel"`
+	File   string `json:"file"`
+	// Kind is "line" (the default), or "box" or "violin" to draw the
+	// distribution of the y values sharing an x value, e.g. raw samples
+	Kind string `json:"kind,omitempty"`
+	// XScale and YScale are "linear" (the default) or "log"; box and violin
+	// plots place the x values side by side and only use YScale
+	XScale string `json:"xScale,omitempty"`
+	YScale string `json:"yScale,omitempty"`
+	// Defaults fills in every field a series leaves out
+	Defaults SeriesSpec   `json:"defaults"`
+	Series   []SeriesSpec `json:"series"`
+}
+
+// SeriesSpec reads one CSV file, or one file per match when Path is a glob.
+// Name can refer to the text matched by the n-th * of Path as {n}, or
+// {n:upper} for the same text in upper case.
+type SeriesSpec struct {
+	Name string      `json:"name,omitempty"`
+	Path string      `json:"path,omitempty"`
+	X    *ColumnSpec `json:"x,omitempty"`
+	Y    *ColumnSpec `json:"y,omitempty"`
+	// Points limits the series to the first rows of the file; 0 reads all
+	Points int         `json:"points,omitempty"`
+	Filter *FilterSpec `json:"filter,omitempty"`
+	// Transform is "rate" to plot x/y (throughput from size and time) or
+	// "power-law-fit" to plot a*x^k fitted through the points
+	Transform string `json:"transform,omitempty"`
+	// Errors draws error bars between two columns of the same row, e.g. the
+	// 95% CI bounds or the median and 95th percentile
+	Errors *ErrorSpec `json:"errors,omitempty"`
+	// Marker is one of markerShapes, or "none" for a plain line; by default
+	// every series gets the next shape
+	Marker string `json:"marker,omitempty"`
+}
+
+// ErrorSpec holds the columns of the lower and upper bound. A missing bound
+// leaves that side of the bar at the point itself.
+type ErrorSpec struct {
+	Low  *ColumnSpec `json:"low,omitempty"`
+	High *ColumnSpec `json:"high,omitempty"`
+}
+
+type ColumnSpec struct {
+	Column int `json:"column"`
+	// Unit reads the column as a duration (integer ns or a Go duration
+	// string) and converts it to ns, us, ms or s
+	Unit   string  `json:"unit,omitempty"`
+	Divide float64 `json:"divide,omitempty"`
+}
+
+// FilterSpec keeps only the rows whose column equals a value, for files
+// that hold several series, like the parallel results per payload size.
+type FilterSpec struct {
+	Column int    `json:"column"`
+	Equals string `json:"equals"`
+}
+
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+func runDrawPlots(args []string) {
+	fs := flag.NewFlagSet("plots", flag.ExitOnError)
+	fs.StringVar(&plotSpecFile, "spec", plotSpecFile, "JSON file with the plot definitions")
+	plotFormatFlag(fs)
+	fs.Parse(args)
+}
+
+func loadPlotSpecs(path string) ([]PlotSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file PlotSpecFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	for _, p := range file.Plots {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("plot %q: %w", p.Title, err)
+		}
+	}
+	return file.Plots, nil
+}
+
+// validate catches typos that would otherwise only show up as a wrong
+// looking plot.
+func (p PlotSpec) validate() error {
+	if p.File == "" {
+		return errors.New("no file")
+	}
+	switch p.Kind {
+	case "", "line", "box", "violin":
+	default:
+		return fmt.Errorf("unknown kind %q", p.Kind)
+	}
+	for _, scale := range []string{p.XScale, p.YScale} {
+		if scale != "" && scale != "linear" && scale != "log" {
+			return fmt.Errorf("unknown scale %q", scale)
+		}
+	}
+	for _, s := range p.Series {
+		marker := s.withDefaults(p.Defaults).Marker
+		if _, ok := markerShapes[marker]; marker != "" && marker != "none" && !ok {
+			return fmt.Errorf("unknown marker %q", marker)
+		}
+	}
+	return nil
+}
+
+func (p PlotSpec) plotConfig() PlotConfig {
+	config := PlotConfig{
+		Title:    p.Title,
+		XLabel:   p.XLabel,
+		YLabel:   p.YLabel,
+		Filepath: plotDir + p.File,
+		Kind:     p.Kind,
+		XLog:     p.XScale == "log",
+		YLog:     p.YScale == "log",
+	}
+	for _, s := range p.Series {
+		config.Series = append(config.Series, s.withDefaults(p.Defaults).expand()...)
+	}
+	return config
+}
+
+func (s SeriesSpec) withDefaults(d SeriesSpec) SeriesSpec {
+	if s.Path == "" {
+		s.Path = d.Path
+	}
+	if s.Name == "" {
+		s.Name = d.Name
+	}
+	if s.X == nil {
+		s.X = d.X
+	}
+	if s.Y == nil {
+		s.Y = d.Y
+	}
+	if s.Points == 0 {
+		s.Points = d.Points
+	}
+	if s.Filter == nil {
+		s.Filter = d.Filter
+	}
+	if s.Transform == "" {
+		s.Transform = d.Transform
+	}
+	if s.Errors == nil {
+		s.Errors = d.Errors
+	}
+	if s.Marker == "" {
+		s.Marker = d.Marker
+	}
+	return s
+}
+
+// expand turns a glob into one series per matching file, so new result
+// files show up without editing the spec.
+func (s SeriesSpec) expand() []PlotSeries {
+	if !strings.ContainsAny(s.Path, "*?[") {
+		path := s.Path
+		return []PlotSeries{{s.Name, s.Marker, func() (SeriesData, error) { return getPointsSeries(path, s) }}}
+	}
+
+	matches, err := filepath.Glob(s.Path)
+	if err == nil && len(matches) == 0 {
+		err = fmt.Errorf("no files match %s", s.Path)
+	}
+	if err != nil {
+		return []PlotSeries{{s.Name, s.Marker, func() (SeriesData, error) { return SeriesData{}, err }}}
+	}
+
+	pattern := globCaptures(s.Path)
+	series := make([]PlotSeries, 0, len(matches))
+	for _, path := range matches {
+		name := s.Name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		}
+		for i, capture := range pattern.FindStringSubmatch(path) {
+			if i > 0 {
+				name = strings.NewReplacer(fmt.Sprintf("{%d}", i), capture, fmt.Sprintf("{%d:upper}", i), strings.ToUpper(capture)).Replace(name)
+			}
+		}
+		series = append(series, PlotSeries{name, s.Marker, func() (SeriesData, error) { return getPointsSeries(path, s) }})
+	}
+	return series
+}
+
+// globCaptures turns the * of a glob into regexp groups; other wildcards are
+// matched by filepath.Glob but cannot be named.
+func globCaptures(glob string) *regexp.Regexp {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "([^/]*)") + "$")
+}
+
+func (c *ColumnSpec) value(row []string) (float64, error) {
+	if c.Column >= len(row) {
+		return 0, fmt.Errorf("expected at least %d columns, got %d", c.Column+1, len(row))
+	}
+
+	var v float64
+	if c.Unit != "" {
+		unit, ok := durationUnits[c.Unit]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q", c.Unit)
+		}
+		d, err := parseDurationColumn(row[c.Column])
+		if err != nil {
+			return 0, err
+		}
+		v = float64(d) / float64(unit)
+	} else {
+		f, err := strconv.ParseFloat(row[c.Column], 64)
+		if err != nil {
+			return 0, err
+		}
+		v = f
+	}
+
+	if c.Divide != 0 {
+		v /= c.Divide
+	}
+	return v, nil
+}
+
+func getPointsSeries(path string, s SeriesSpec) (SeriesData, error) {
+	if s.Y == nil {
+		return SeriesData{}, errors.New("series has no y column")
+	}
+
+	_, rows, env, err := readCsvTable(path)
+	if err != nil {
+		return SeriesData{}, err
+	}
+
+	pts, errs, err := seriesPoints(path, s, rows)
+	if err != nil {
+		return SeriesData{}, err
+	}
+	data := SeriesData{Points: pts, Errors: errs}
+	if env != nil {
+		data.Environment = env.summary()
+	}
+	return data, nil
+}
+
+// seriesPoints returns the points of a series and, when it has error bars,
+// how far each bar reaches below and above its point.
+func seriesPoints(path string, s SeriesSpec, rows [][]string) (plotter.XYs, plotter.YErrors, error) {
+	x := s.X
+	if x == nil {
+		x = &ColumnSpec{}
+	}
+
+	pts := make(plotter.XYs, 0)
+	var bounds [][2]float64
+	for i, row := range rows {
+		if s.Points > 0 && len(pts) == s.Points {
+			break
+		}
+		if s.Filter != nil && (s.Filter.Column >= len(row) || row[s.Filter.Column] != s.Filter.Equals) {
+			continue
+		}
+
+		px, err := x.value(row)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing column %d in %s (row %d): %w", x.Column, path, i, err)
+		}
+		py, err := s.Y.value(row)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing column %d in %s (row %d): %w", s.Y.Column, path, i, err)
+		}
+		pts = append(pts, plotter.XY{X: px, Y: py})
+
+		if s.Errors != nil {
+			low, high := py, py
+			if c := s.Errors.Low; c != nil {
+				if low, err = c.value(row); err != nil {
+					return nil, nil, fmt.Errorf("error parsing column %d in %s (row %d): %w", c.Column, path, i, err)
+				}
+			}
+			if c := s.Errors.High; c != nil {
+				if high, err = c.value(row); err != nil {
+					return nil, nil, fmt.Errorf("error parsing column %d in %s (row %d): %w", c.Column, path, i, err)
+				}
+			}
+			bounds = append(bounds, [2]float64{low, high})
+		}
+	}
+	if len(pts) == 0 && s.Filter != nil {
+		return nil, nil, fmt.Errorf("no rows with column %d = %s in %s", s.Filter.Column, s.Filter.Equals, path)
+	}
+
+	switch s.Transform {
+	case "":
+	case "rate":
+		rate := func(x, y float64) float64 {
+			if y > 0 {
+				return x / y
+			}
+			return 0
+		}
+		for i := range pts {
+			// a longer time is a lower rate, so the bounds swap
+			if bounds != nil {
+				bounds[i] = [2]float64{rate(pts[i].X, bounds[i][1]), rate(pts[i].X, bounds[i][0])}
+			}
+			pts[i].Y = rate(pts[i].X, pts[i].Y)
+		}
+	case "power-law-fit":
+		fit, err := powerLawFitPoints(pts, path)
+		return fit, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unknown transform %q", s.Transform)
+	}
+
+	if bounds == nil {
+		return pts, nil, nil
+	}
+	errs := make(plotter.YErrors, len(pts))
+	for i, b := range bounds {
+		errs[i].Low = math.Max(pts[i].Y-b[0], 0)
+		errs[i].High = math.Max(b[1]-pts[i].Y, 0)
+	}
+	return pts, errs, nil
+}
+
+// powerLawFitPoints fits y = a * x^k through the points and samples the
+// fitted curve between the first and last x.
+func powerLawFitPoints(measured plotter.XYs, path string) (plotter.XYs, error) {
+	if len(measured) < 2 {
+		return nil, fmt.Errorf("need at least two points in %s to fit", path)
+	}
+
+	xs, ys := make([]float64, len(measured)), make([]float64, len(measured))
+	for i, pt := range measured {
+		xs[i], ys[i] = pt.X, pt.Y
+	}
+	a, k, _ := fitPowerLaw(xs, ys)
+
+	const steps = 50
+	first, last := xs[0], xs[len(xs)-1]
+	pts := make(plotter.XYs, 0, steps+1)
+	for i := range steps + 1 {
+		x := first + (last-first)*float64(i)/steps
+		pts = append(pts, plotter.XY{X: x, Y: a * math.Pow(x, k)})
+	}
+	return pts, nil
+}
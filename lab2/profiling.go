@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// MemoryStats is the allocation and GC activity between two runtime.MemStats
+// snapshots, normalized per measured operation.
+type MemoryStats struct {
+	allocsPerOp float64
+	bytesPerOp  float64
+	gcCycles    uint32
+	gcPause     time.Duration
+}
+
+// captureProfiles enables CPU and heap profiles for every algorithm x size
+// cell, written to a pprof/ directory next to the summary CSVs.
+var captureProfiles bool
+
+func newMemoryStats(before, after *runtime.MemStats, ops int) MemoryStats {
+	if ops <= 0 {
+		return MemoryStats{}
+	}
+	return MemoryStats{
+		allocsPerOp: float64(after.Mallocs-before.Mallocs) / float64(ops),
+		bytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / float64(ops),
+		gcCycles:    after.NumGC - before.NumGC,
+		gcPause:     time.Duration(after.PauseTotalNs - before.PauseTotalNs),
+	}
+}
+
+func (m MemoryStats) plus(other MemoryStats) MemoryStats {
+	return MemoryStats{
+		allocsPerOp: m.allocsPerOp + other.allocsPerOp,
+		bytesPerOp:  m.bytesPerOp + other.bytesPerOp,
+		gcCycles:    m.gcCycles + other.gcCycles,
+		gcPause:     m.gcPause + other.gcPause,
+	}
+}
+
+func memoryStatsHeader(prefix string) []string {
+	return []string{prefix + "Allocs/op", prefix + "Bytes/op", prefix + "GC cycles", prefix + "GC pause (ns)"}
+}
+
+func memoryStatsColumns(m MemoryStats) []string {
+	return []string{
+		fmt.Sprintf("%.2f", m.allocsPerOp),
+		fmt.Sprintf("%.2f", m.bytesPerOp),
+		fmt.Sprint(m.gcCycles),
+		fmt.Sprint(m.gcPause.Nanoseconds()),
+	}
+}
+
+// startProfile starts a CPU profile for one cell and returns a function that
+// stops it and writes the heap profile. The heap profile is cumulative since
+// program start; compare two cells with `go tool pprof -diff_base`.
+func startProfile(prefix string, value int) func() {
+	if !captureProfiles || prefix == "" {
+		return func() {}
+	}
+
+	base := fmt.Sprintf("%s_%d", prefix, value)
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		log.Fatalf("error during directory creation: %v", err)
+	}
+
+	cpuFile, err := os.Create(base + ".cpu.pprof")
+	if err != nil {
+		log.Fatalf("error creating CPU profile: %v", err)
+	}
+	if err := pprof.StartCPUProfile(cpuFile); err != nil {
+		log.Fatalf("error starting CPU profile: %v", err)
+	}
+
+	return func() {
+		pprof.StopCPUProfile()
+		cpuFile.Close()
+
+		heapFile, err := os.Create(base + ".heap.pprof")
+		if err != nil {
+			log.Fatalf("error creating heap profile: %v", err)
+		}
+		defer heapFile.Close()
+
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(heapFile); err != nil {
+			log.Fatalf("error writing heap profile: %v", err)
+		}
+	}
+}
+
+// profilePrefix is the per-algorithm path prefix for startProfile.
+func profilePrefix(dir, algorithm string) string {
+	return filepath.Join(dir, "pprof", benchstatName(algorithm))
+}
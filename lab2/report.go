@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	reportFormatHTML     = "html"
+	reportFormatMarkdown = "md"
+)
+
+// reportImageTypes lists the plot files a report can show, in order of
+// preference; PDFs cannot be shown inline.
+var reportImageTypes = map[string]string{
+	".svg": "image/svg+xml",
+	".png": "image/png",
+}
+
+var reportImageOrder = []string{".svg", ".png"}
+
+type Report struct {
+	Title        string
+	Generated    time.Time
+	Environments []*ReportEnvironment
+	Sections     []*ReportSection
+	Plots        []*ReportPlot
+}
+
+// ReportEnvironment is one machine and run the result files were measured
+// on, identified like the plot subtitles.
+type ReportEnvironment struct {
+	Summary string
+	Fields  [][2]string
+	Files   int
+}
+
+// ReportSection holds the result tables of one directory below results/.
+type ReportSection struct {
+	Name   string
+	Tables []*ReportTable
+}
+
+type ReportTable struct {
+	Path   string
+	Header []string
+	Rows   [][]string
+}
+
+type ReportPlot struct {
+	Title string
+	Path  string
+}
+
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	dir := flags.String("dir", "results", "results directory to report on")
+	format := flags.String("format", reportFormatHTML, "html (one file with the plots embedded) or md (plots linked relative to the report)")
+	out := flags.String("out", "", "report file (default <dir>/report.html or <dir>/report.md)")
+	flags.StringVar(&plotSpecFile, "spec", plotSpecFile, "JSON file with the plot definitions, used for the order and titles of the plots")
+	flags.Parse(args)
+
+	if *format != reportFormatHTML && *format != reportFormatMarkdown {
+		log.Fatalf("unsupported report format %q, expected %s or %s", *format, reportFormatHTML, reportFormatMarkdown)
+	}
+	if *out == "" {
+		*out = filepath.Join(*dir, "report."+*format)
+	}
+
+	report, err := buildReport(*dir)
+	if err != nil {
+		log.Fatalf("error reading %s: %v", *dir, err)
+	}
+
+	var buf bytes.Buffer
+	if *format == reportFormatHTML {
+		err = writeHTMLReport(&buf, report)
+	} else {
+		err = writeMarkdownReport(&buf, report, filepath.Dir(*out))
+	}
+	if err != nil {
+		log.Fatalf("error writing report: %v", err)
+	}
+	writeResultFile(*out, buf.Bytes())
+
+	tables := 0
+	for _, section := range report.Sections {
+		tables += len(section.Tables)
+	}
+	fmt.Printf("Wrote %s (%d environments, %d tables, %d plots)\n", *out, len(report.Environments), tables, len(report.Plots))
+}
+
+func buildReport(dir string) (*Report, error) {
+	report := &Report{Title: "Lab 2 benchmark report", Generated: time.Now().UTC()}
+	environments := map[string]*ReportEnvironment{}
+	sections := map[string]*ReportSection{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); name == "raw" || name == "plots" || name == "compare" || name == "pprof" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".csv" {
+			return nil
+		}
+
+		header, rows, env, err := readCsvTable(path)
+		if err != nil {
+			log.Printf("  [!] Skipping %s: %v", path, err)
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if env != nil {
+			summary := env.summary()
+			e, ok := environments[summary]
+			if !ok {
+				e = &ReportEnvironment{Summary: summary}
+				for _, field := range env.fields() {
+					if field[1] != "" && field[1] != "0" {
+						e.Fields = append(e.Fields, field)
+					}
+				}
+				environments[summary] = e
+				report.Environments = append(report.Environments, e)
+			}
+			e.Files++
+		}
+
+		name, _, found := strings.Cut(rel, "/")
+		if !found {
+			name = "."
+		}
+		section, ok := sections[name]
+		if !ok {
+			section = &ReportSection{Name: name}
+			sections[name] = section
+			report.Sections = append(report.Sections, section)
+		}
+		section.Tables = append(section.Tables, newReportTable(rel, header, rows))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Plots = findReportPlots(filepath.Join(dir, "plots"))
+	return report, nil
+}
+
+// newReportTable drops the columns that are zero or empty in every row, like
+// the cold columns of results measured without cold runs, and prints the ns
+// columns as durations.
+func newReportTable(path string, header []string, rows [][]string) *ReportTable {
+	table := &ReportTable{Path: path, Rows: make([][]string, len(rows))}
+	for col, name := range header {
+		used := slices.ContainsFunc(rows, func(row []string) bool {
+			return col < len(row) && row[col] != "" && row[col] != "0"
+		})
+		if len(rows) > 0 && !used {
+			continue
+		}
+
+		name, ns := strings.CutSuffix(name, " (ns)")
+		table.Header = append(table.Header, name)
+		for i, row := range rows {
+			var value string
+			if col < len(row) {
+				value = row[col]
+			}
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && ns {
+				value = time.Duration(n).String()
+			}
+			table.Rows[i] = append(table.Rows[i], value)
+		}
+	}
+	return table
+}
+
+// findReportPlots lists the plots in the order of the plot definitions,
+// followed by any other image in the plot directory, such as the RSA key
+// generation histograms and the ECB penguin.
+func findReportPlots(dir string) []*ReportPlot {
+	var plots []*ReportPlot
+	shown := map[string]bool{}
+	add := func(title, base string) {
+		for _, ext := range reportImageOrder {
+			path := filepath.Join(dir, base+ext)
+			if _, err := os.Stat(path); err == nil {
+				plots = append(plots, &ReportPlot{Title: title, Path: path})
+				shown[base] = true
+				return
+			}
+		}
+	}
+
+	specs, err := loadPlotSpecs(plotSpecFile)
+	if err != nil {
+		log.Printf("  [!] Listing plots without their titles: %v", err)
+	}
+	for _, spec := range specs {
+		add(spec.Title, strings.TrimSuffix(spec.File, filepath.Ext(spec.File)))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("  [!] No plots in the report: %v", err)
+		return plots
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		base := strings.TrimSuffix(entry.Name(), ext)
+		if _, ok := reportImageTypes[ext]; ok && !shown[base] {
+			add(base, base)
+		}
+	}
+	return plots
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"dataURI": func(path string) (template.URL, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		mime := reportImageTypes[filepath.Ext(path)]
+		return template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 70em; padding: 0 1em; }
+table { border-collapse: collapse; font-size: 0.85em; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: right; white-space: nowrap; }
+th { background: #eee; }
+.scroll { overflow-x: auto; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+
+<h2>Environment</h2>
+{{range .Environments}}
+<h3>{{.Summary}}</h3>
+<p>{{.Files}} result files</p>
+<table>
+{{range .Fields}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No result file records its environment.</p>
+{{end}}
+
+<h2>Results</h2>
+{{range .Sections}}
+<h3>{{.Name}}</h3>
+{{range .Tables}}
+<h4>{{.Path}}</h4>
+<div class="scroll"><table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table></div>
+{{end}}
+{{end}}
+
+<h2>Plots</h2>
+{{range .Plots}}
+<h3>{{.Title}}</h3>
+<img src="{{dataURI .Path}}" alt="{{.Title}}">
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTMLReport(w io.Writer, report *Report) error {
+	return htmlReportTemplate.Execute(w, report)
+}
+
+// writeMarkdownReport links the plots relative to dir, the directory of the
+// report, since Markdown viewers rarely show embedded images.
+func writeMarkdownReport(w io.Writer, report *Report, dir string) error {
+	fmt.Fprintf(w, "# %s\n\nGenerated %s\n\n## Environment\n\n", report.Title, report.Generated.Format("2006-01-02 15:04:05 MST"))
+	if len(report.Environments) == 0 {
+		fmt.Fprintf(w, "No result file records its environment.\n\n")
+	}
+	for _, env := range report.Environments {
+		fmt.Fprintf(w, "### %s\n\n%d result files\n\n", markdownEscape(env.Summary), env.Files)
+		rows := make([][]string, len(env.Fields))
+		for i, field := range env.Fields {
+			rows[i] = field[:]
+		}
+		writeMarkdownTable(w, []string{"Key", "Value"}, rows)
+	}
+
+	fmt.Fprintf(w, "## Results\n\n")
+	for _, section := range report.Sections {
+		fmt.Fprintf(w, "### %s\n\n", markdownEscape(section.Name))
+		for _, table := range section.Tables {
+			fmt.Fprintf(w, "#### %s\n\n", markdownEscape(table.Path))
+			writeMarkdownTable(w, table.Header, table.Rows)
+		}
+	}
+
+	fmt.Fprintf(w, "## Plots\n\n")
+	for _, p := range report.Plots {
+		path, err := filepath.Rel(dir, p.Path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "### %s\n\n![%s](%s)\n\n", markdownEscape(p.Title), markdownEscape(p.Title), filepath.ToSlash(path))
+	}
+	return nil
+}
+
+func writeMarkdownTable(w io.Writer, header []string, rows [][]string) {
+	line := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = markdownEscape(cell)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+	}
+
+	line(header)
+	fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+	for _, row := range rows {
+		line(row)
+	}
+	fmt.Fprintln(w)
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`).Replace(s)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+)
+
+type RSAAttackResult struct {
+	name      string
+	bits      int
+	recovered bool
+	elapsed   time.Duration
+	detail    string
+}
+
+// runRSAAttacks runs every attack against freshly generated toy keys and
+// textbook (unpadded) encryption, which is what each of them relies on.
+func runRSAAttacks(bits int) {
+	fmt.Println("=== RSA ATTACKS ON TEXTBOOK RSA ===")
+
+	attacks := []struct {
+		name string
+		run  func(bits int) (bool, string, error)
+	}{
+		{"small-e cube root", demoCubeRootAttack},
+		{"Hastad broadcast", demoHastadAttack},
+		{"common modulus", demoCommonModulusAttack},
+		{"Wiener small d", demoWienerAttack},
+	}
+
+	results := []*RSAAttackResult{}
+	for _, a := range attacks {
+		start := time.Now()
+		recovered, detail, err := a.run(bits)
+		elapsed := time.Since(start)
+		if err != nil {
+			log.Fatalf("Error running %s attack: %v", a.name, err)
+		}
+
+		fmt.Printf("%s (RSA-%d): recovered %v in %v\n  %s\n", a.name, bits, recovered, elapsed, detail)
+		results = append(results, &RSAAttackResult{a.name, bits, recovered, elapsed, detail})
+	}
+
+	exportRSAAttacks(results, "results/toy_rsa/attacks.csv")
+}
+
+// demoCubeRootAttack encrypts a short message with e = 3. When m^3 < n the
+// modular reduction never happens and the plaintext is the integer cube root
+// of the ciphertext.
+func demoCubeRootAttack(bits int) (bool, string, error) {
+	key, err := toyGenerateKey(bits, 3)
+	if err != nil {
+		return false, "", err
+	}
+
+	message := []byte("short secret, no padding")
+	c := key.toyEncryptTextbook(new(big.Int).SetBytes(message))
+
+	recovered := integerRoot(c, 3)
+	ok := bytes.Equal(recovered.Bytes(), message)
+	return ok, fmt.Sprintf("m has %d bits, m^3 has %d bits < %d-bit n", 8*len(message), c.BitLen(), key.n.BitLen()), nil
+}
+
+// demoHastadAttack sends one full-size message to three recipients with
+// e = 3. The plain cube root fails, but the CRT gives m^3 mod n1*n2*n3,
+// which is m^3 itself because m < every ni.
+func demoHastadAttack(bits int) (bool, string, error) {
+	keys := make([]*ToyRSAKey, 3)
+	for i := range keys {
+		key, err := toyGenerateKey(bits, 3)
+		if err != nil {
+			return false, "", err
+		}
+		keys[i] = key
+	}
+
+	m := new(big.Int).SetBytes(randomBytes(bits/8 - 2))
+	ciphertexts := make([]*big.Int, len(keys))
+	moduli := make([]*big.Int, len(keys))
+	for i, key := range keys {
+		ciphertexts[i] = key.toyEncryptTextbook(m)
+		moduli[i] = key.n
+	}
+
+	cubeRootFails := integerRoot(ciphertexts[0], 3).Cmp(m) != 0
+
+	combined, err := chineseRemainder(ciphertexts, moduli)
+	if err != nil {
+		return false, "", err
+	}
+	recovered := integerRoot(combined, 3)
+	return recovered.Cmp(m) == 0, fmt.Sprintf("%d-bit m, cube root of one ciphertext fails: %v", m.BitLen(), cubeRootFails), nil
+}
+
+// demoCommonModulusAttack encrypts one message under two keys sharing n with
+// coprime exponents. With a*e1 + b*e2 = 1, c1^a * c2^b = m.
+func demoCommonModulusAttack(bits int) (bool, string, error) {
+	e1, e2 := big.NewInt(65537), big.NewInt(257)
+
+	// both exponents have to be invertible, so primes are checked against
+	// their product
+	exponents := new(big.Int).Mul(e1, e2)
+	var p, q *big.Int
+	for p == nil || q == nil || p.Cmp(q) == 0 {
+		var err error
+		if p, err = toyGeneratePrime(bits-bits/2, exponents); err != nil {
+			return false, "", err
+		}
+		if q, err = toyGeneratePrime(bits/2, exponents); err != nil {
+			return false, "", err
+		}
+	}
+	key1, err := toyKeyFromPrimes(p, q, e1)
+	if err != nil {
+		return false, "", err
+	}
+	key2, err := toyKeyFromPrimes(p, q, e2)
+	if err != nil {
+		return false, "", err
+	}
+
+	m := new(big.Int).SetBytes(randomBytes(bits/8 - 1))
+	c1 := key1.toyEncryptTextbook(m)
+	c2 := key2.toyEncryptTextbook(m)
+
+	a, b := new(big.Int), new(big.Int)
+	new(big.Int).GCD(a, b, e1, e2)
+	recovered := new(big.Int).Mul(powSigned(c1, a, key1.n), powSigned(c2, b, key1.n))
+	recovered.Mod(recovered, key1.n)
+	return recovered.Cmp(m) == 0, fmt.Sprintf("e1 = %v, e2 = %v, a = %v, b = %v", e1, e2, a, b), nil
+}
+
+// demoWienerAttack builds a key with d < n^(1/4)/3. Then k/d is one of the
+// continued fraction convergents of e/n, and each candidate d gives phi(n)
+// and with it p and q as roots of x^2 - (n - phi + 1)x + n.
+func demoWienerAttack(bits int) (bool, string, error) {
+	p, err := toyGeneratePrime(bits-bits/2, bigOne)
+	if err != nil {
+		return false, "", err
+	}
+	q, err := toyGeneratePrime(bits/2, bigOne)
+	if err != nil {
+		return false, "", err
+	}
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, bigOne), new(big.Int).Sub(q, bigOne))
+
+	var d, e *big.Int
+	for e == nil {
+		if d, err = rand.Prime(rand.Reader, bits/4-4); err != nil {
+			return false, "", err
+		}
+		e = new(big.Int).ModInverse(d, phi)
+	}
+	key, err := toyKeyFromPrimes(p, q, e)
+	if err != nil {
+		return false, "", err
+	}
+
+	recovered, convergents, err := wienerAttack(key.e, key.n)
+	if err != nil {
+		return false, err.Error(), nil
+	}
+	return recovered.Cmp(key.d) == 0, fmt.Sprintf("%d-bit d found at convergent %d", d.BitLen(), convergents), nil
+}
+
+func wienerAttack(e, n *big.Int) (*big.Int, int, error) {
+	// convergents k/d of the continued fraction [a0; a1, a2, ...] of e/n
+	num, den := new(big.Int).Set(e), new(big.Int).Set(n)
+	kPrev, k := big.NewInt(0), big.NewInt(1)
+	dPrev, d := big.NewInt(1), big.NewInt(0)
+	a, r := new(big.Int), new(big.Int)
+
+	for i := 1; den.Sign() != 0; i++ {
+		a.QuoRem(num, den, r)
+		num.Set(den)
+		den.Set(r)
+
+		kPrev, k = k, new(big.Int).Add(new(big.Int).Mul(a, k), kPrev)
+		dPrev, d = d, new(big.Int).Add(new(big.Int).Mul(a, d), dPrev)
+		if k.Sign() == 0 {
+			continue
+		}
+
+		// e*d = 1 + k*phi
+		edMinusOne := new(big.Int).Mul(e, d)
+		edMinusOne.Sub(edMinusOne, bigOne)
+		phi, rem := new(big.Int).QuoRem(edMinusOne, k, new(big.Int))
+		if rem.Sign() != 0 {
+			continue
+		}
+
+		// p + q = n - phi + 1, and p, q = (s ± sqrt(s^2 - 4n)) / 2
+		s := new(big.Int).Sub(n, phi)
+		s.Add(s, bigOne)
+		discriminant := new(big.Int).Mul(s, s)
+		discriminant.Sub(discriminant, new(big.Int).Lsh(n, 2))
+		if discriminant.Sign() < 0 {
+			continue
+		}
+		root := new(big.Int).Sqrt(discriminant)
+		if new(big.Int).Mul(root, root).Cmp(discriminant) != 0 {
+			continue
+		}
+		p := new(big.Int).Add(s, root)
+		p.Rsh(p, 1)
+		if new(big.Int).Mod(n, p).Sign() == 0 {
+			return d, i, nil
+		}
+	}
+	return nil, 0, errors.New("no convergent factors n")
+}
+
+// integerRoot returns floor(x^(1/k)) by Newton's method.
+func integerRoot(x *big.Int, k int) *big.Int {
+	if x.Sign() == 0 {
+		return new(big.Int)
+	}
+	kBig := big.NewInt(int64(k))
+	kMinusOne := big.NewInt(int64(k - 1))
+
+	// start above the root: 2^ceil(bitlen/k)
+	y := new(big.Int).Lsh(bigOne, uint((x.BitLen()+k-1)/k))
+	for {
+		// y' = ((k-1)y + x / y^(k-1)) / k
+		next := new(big.Int).Exp(y, kMinusOne, nil)
+		next.Quo(x, next)
+		next.Add(next, new(big.Int).Mul(kMinusOne, y))
+		next.Quo(next, kBig)
+		if next.Cmp(y) >= 0 {
+			return y
+		}
+		y = next
+	}
+}
+
+// chineseRemainder returns x mod prod(moduli) with x = residues[i] mod
+// moduli[i] for pairwise coprime moduli.
+func chineseRemainder(residues, moduli []*big.Int) (*big.Int, error) {
+	product := big.NewInt(1)
+	for _, m := range moduli {
+		product.Mul(product, m)
+	}
+
+	x := new(big.Int)
+	for i, m := range moduli {
+		rest := new(big.Int).Quo(product, m)
+		inverse := new(big.Int).ModInverse(rest, m)
+		if inverse == nil {
+			return nil, errors.New("moduli are not pairwise coprime")
+		}
+		term := new(big.Int).Mul(residues[i], rest)
+		term.Mul(term, inverse)
+		x.Add(x, term)
+	}
+	return x.Mod(x, product), nil
+}
+
+// powSigned computes x^y mod n for a possibly negative y.
+func powSigned(x, y, n *big.Int) *big.Int {
+	if y.Sign() >= 0 {
+		return new(big.Int).Exp(x, y, n)
+	}
+	inverse := new(big.Int).ModInverse(x, n)
+	return inverse.Exp(inverse, new(big.Int).Neg(y), n)
+}
+
+func exportRSAAttacks(results []*RSAAttackResult, filename string) {
+	records := [][]string{{"Attack", "Bits", "Recovered", "Time (ns)", "Detail"}}
+	for _, r := range results {
+		records = append(records, []string{
+			r.name,
+			fmt.Sprint(r.bits),
+			fmt.Sprint(r.recovered),
+			fmt.Sprint(r.elapsed.Nanoseconds()),
+			r.detail,
+		})
+	}
+	exportToCSV(filename, records)
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+const rsaSweepDir = "results/rsa_sweep/"
+
+type RSASweepResult struct {
+	bits         int
+	durations    []time.Duration
+	result       *BenchmarkResult
+	instrumented []*PrimeStats
+}
+
+// PrimeStats counts the work done by the instrumented generator for one key:
+// every random candidate, the ones rejected by trial division and the ones
+// that needed a full probabilistic primality test.
+type PrimeStats struct {
+	duration       time.Duration
+	candidates     int
+	sieved         int
+	primalityTests int
+}
+
+// smallPrimesProduct is 3*5*...*53, which fits in a uint64, so trial division
+// needs a single big.Int reduction per candidate.
+var (
+	smallPrimes        = []uint64{3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53}
+	smallPrimesProduct = new(big.Int).SetUint64(16294579238595022365)
+)
+
+func runRSASweep(args []string) {
+	fs := flag.NewFlagSet("rsa-sweep", flag.ExitOnError)
+	plotFormatFlag(fs)
+	bitsList := fs.String("bits", "1024,2048,3072,4096,8192", "comma separated RSA key sizes")
+	keys := fs.Int("keys", 0, "keys per size (0 uses 200/50/20/10/2 for 1024/2048/3072/4096/8192)")
+	instrumented := fs.Bool("instrumented", false, "also run a math/big generator that counts candidates and primality tests")
+	fs.Parse(args)
+
+	sizes := []int{}
+	for _, field := range strings.Split(*bitsList, ",") {
+		bits, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			log.Fatalf("invalid key size %q", field)
+		}
+		// crypto/rsa refuses to generate anything smaller
+		if bits < 1024 {
+			log.Fatalf("invalid key size %d: crypto/rsa needs at least 1024 bits", bits)
+		}
+		sizes = append(sizes, bits)
+	}
+
+	fmt.Println("=== RSA KEY SIZE SWEEP ===")
+
+	results := []*RSASweepResult{}
+	for _, bits := range sizes {
+		keyNums := *keys
+		if keyNums <= 0 {
+			keyNums = defaultRSASweepKeys(bits)
+		}
+
+		fmt.Printf("Generating %d RSA-%d keys...\n", keyNums, bits)
+		durations, memory := measureTimeRSA(keyNums, bits)
+		result := &RSASweepResult{bits: bits, durations: durations, result: calculateBenchmarkResult(durations)}
+		result.result.memory = memory
+		fmt.Printf("  mean %v, median %v, min %v, max %v, stddev %v\n",
+			result.result.mean, result.result.median, result.result.min, result.result.max, result.result.stddev)
+
+		if *instrumented {
+			for range keyNums {
+				_, stats, err := generateInstrumentedRSAKey(bits)
+				if err != nil {
+					log.Fatalf("Error generating instrumented RSA-%d key: %v", bits, err)
+				}
+				result.instrumented = append(result.instrumented, stats)
+			}
+			candidates, tests := meanPrimeStats(result.instrumented)
+			fmt.Printf("  instrumented: %.1f candidates and %.1f primality tests per key (expected ~%.1f candidates)\n",
+				candidates, tests, expectedRSACandidates(bits))
+		}
+
+		exportRSASweepKeys(result, fmt.Sprintf("%srsa%d.csv", rsaSweepDir, bits))
+		drawRSAHistogram(result, fmt.Sprintf("%srsa_keygen_hist_%d.png", plotDir, bits))
+		results = append(results, result)
+	}
+
+	exportRSASweepSummary(results, rsaSweepDir+"summary.csv")
+	if *instrumented {
+		exportRSASweepPrimeSearch(results, rsaSweepDir+"prime_search.csv")
+	} else if err := os.Remove(rsaSweepDir + "prime_search.csv"); err == nil {
+		// a stale file from an earlier instrumented run would not match this one
+		os.Remove(environmentPath(rsaSweepDir + "prime_search.csv"))
+	}
+
+	if len(results) >= 2 {
+		xs, ys := make([]float64, len(results)), make([]float64, len(results))
+		for i, r := range results {
+			xs[i], ys[i] = float64(r.bits), r.result.mean.Seconds()
+		}
+		a, k, r2 := fitPowerLaw(xs, ys)
+		fmt.Printf("\nFitted mean key generation time: t(bits) = %.3g s * bits^%.2f (R^2 = %.3f)\n", a, k, r2)
+		fmt.Println("Prime search predicts roughly bits^4: bits/ln(2) candidates per prime, each costing a bits^3 modular exponentiation.")
+	}
+}
+
+func defaultRSASweepKeys(bits int) int {
+	switch {
+	case bits <= 1024:
+		return 200
+	case bits <= 2048:
+		return 50
+	case bits <= 3072:
+		return 20
+	case bits <= 4096:
+		return 10
+	default:
+		return 2
+	}
+}
+
+// expectedRSACandidates is the number of random odd candidates needed for two
+// primes of bits/2 by the prime number theorem: ln(2^(bits/2))/2 each.
+func expectedRSACandidates(bits int) float64 {
+	return 2 * float64(bits/2) * math.Ln2 / 2
+}
+
+// generateInstrumentedRSAKey builds a two-prime key with e = 65537 from
+// primes found by generateInstrumentedPrime. It mirrors what crypto/rsa does
+// but is not hardened and is only meant for counting.
+func generateInstrumentedRSAKey(bits int) (*rsa.PrivateKey, *PrimeStats, error) {
+	stats := &PrimeStats{}
+	start := time.Now()
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+
+	for {
+		p, err := generateInstrumentedPrime(bits-bits/2, stats)
+		if err != nil {
+			return nil, nil, err
+		}
+		q, err := generateInstrumentedPrime(bits/2, stats)
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		stats.duration = time.Since(start)
+		return key, stats, nil
+	}
+}
+
+func generateInstrumentedPrime(bits int, stats *PrimeStats) (*big.Int, error) {
+	return searchPrime(bits, func(candidate *big.Int) (bool, error) {
+		return candidate.ProbablyPrime(20), nil
+	}, stats)
+}
+
+// searchPrime draws random candidates of exactly bits bits, odd and with the
+// top two bits set so that the product of two has the full length, sieves out
+// those with a small prime factor and returns the first one isPrime accepts.
+// stats may be nil.
+func searchPrime(bits int, isPrime func(*big.Int) (bool, error), stats *PrimeStats) (*big.Int, error) {
+	if bits < 2 {
+		return nil, errors.New("prime size must be at least 2 bits")
+	}
+	if stats == nil {
+		stats = &PrimeStats{}
+	}
+
+	buf := make([]byte, (bits+7)/8)
+	candidate := new(big.Int)
+	remainder := new(big.Int)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		candidate.SetBytes(buf)
+		for i := candidate.BitLen() - 1; i >= bits; i-- {
+			candidate.SetBit(candidate, i, 0)
+		}
+		candidate.SetBit(candidate, bits-1, 1)
+		candidate.SetBit(candidate, bits-2, 1)
+		candidate.SetBit(candidate, 0, 1)
+		stats.candidates++
+
+		m := remainder.Mod(candidate, smallPrimesProduct).Uint64()
+		divisible := false
+		for _, prime := range smallPrimes {
+			if m%prime == 0 {
+				divisible = true
+				break
+			}
+		}
+		if divisible {
+			stats.sieved++
+			continue
+		}
+
+		stats.primalityTests++
+		prime, err := isPrime(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if prime {
+			return new(big.Int).Set(candidate), nil
+		}
+	}
+}
+
+func meanPrimeStats(stats []*PrimeStats) (float64, float64) {
+	if len(stats) == 0 {
+		return 0, 0
+	}
+	candidates, tests := 0, 0
+	for _, s := range stats {
+		candidates += s.candidates
+		tests += s.primalityTests
+	}
+	return float64(candidates) / float64(len(stats)), float64(tests) / float64(len(stats))
+}
+
+// fitPowerLaw fits y = a * x^k by least squares on ln y = ln a + k ln x and
+// returns a, k and the coefficient of determination in log space.
+func fitPowerLaw(xs, ys []float64) (float64, float64, float64) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXX, sumXY float64
+	for i := range xs {
+		x, y := math.Log(xs[i]), math.Log(ys[i])
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+
+	k := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	lnA := (sumY - k*sumX) / n
+
+	meanY := sumY / n
+	var ssTot, ssRes float64
+	for i := range xs {
+		y := math.Log(ys[i])
+		predicted := lnA + k*math.Log(xs[i])
+		ssTot += (y - meanY) * (y - meanY)
+		ssRes += (y - predicted) * (y - predicted)
+	}
+	r2 := 1.0
+	if ssTot > 0 {
+		r2 = 1 - ssRes/ssTot
+	}
+	return math.Exp(lnA), k, r2
+}
+
+func exportRSASweepKeys(result *RSASweepResult, filepath string) {
+	header := []string{"Key", "Duration (ns)"}
+	if result.instrumented != nil {
+		header = append(header, "Instrumented duration (ns)", "Candidates", "Sieved", "Primality tests")
+	}
+
+	records := [][]string{header}
+	for i, duration := range result.durations {
+		row := []string{fmt.Sprint(i), fmt.Sprint(duration.Nanoseconds())}
+		if i < len(result.instrumented) {
+			s := result.instrumented[i]
+			row = append(row, fmt.Sprint(s.duration.Nanoseconds()), fmt.Sprint(s.candidates), fmt.Sprint(s.sieved), fmt.Sprint(s.primalityTests))
+		}
+		records = append(records, row)
+	}
+	exportToCSV(filepath, records)
+}
+
+func exportRSASweepSummary(results []*RSASweepResult, filepath string) {
+	header := append([]string{"Bits"}, benchmarkResultHeader("")...)
+	header = append(header, memoryStatsHeader("")...)
+	records := [][]string{header}
+	for _, r := range results {
+		row := append([]string{fmt.Sprint(r.bits)}, benchmarkResultColumns(r.result)...)
+		records = append(records, append(row, memoryStatsColumns(r.result.memory)...))
+	}
+	exportToCSV(filepath, records)
+}
+
+// exportRSASweepPrimeSearch is only written by instrumented runs, so the
+// summary never carries empty columns.
+func exportRSASweepPrimeSearch(results []*RSASweepResult, filepath string) {
+	records := [][]string{{"Bits", "Mean candidates", "Mean primality tests", "Expected candidates"}}
+	for _, r := range results {
+		candidates, tests := meanPrimeStats(r.instrumented)
+		records = append(records, []string{fmt.Sprint(r.bits), fmt.Sprintf("%.2f", candidates), fmt.Sprintf("%.2f", tests), fmt.Sprintf("%.2f", expectedRSACandidates(r.bits))})
+	}
+	exportToCSV(filepath, records)
+}
+
+func drawRSAHistogram(result *RSASweepResult, filename string) {
+	values := make(plotter.Values, len(result.durations))
+	for i, duration := range result.durations {
+		values[i] = float64(duration) / float64(time.Millisecond)
+	}
+
+	bins := min(max(int(math.Ceil(math.Sqrt(float64(len(values))))), 1), 30)
+	hist, err := plotter.NewHist(values, bins)
+	if err != nil {
+		log.Printf("  [!] Skipping histogram for RSA-%d: %v", result.bits, err)
+		return
+	}
+
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("RSA-%d key generation time distribution (%d keys)\n%s",
+		result.bits, len(values), currentEnvironment().summary())
+	p.X.Label.Text = "Time (ms)"
+	p.Y.Label.Text = "Keys"
+	p.Add(hist)
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		log.Printf("  [!] ERROR creating plot directory: %v", err)
+		return
+	}
+	savePlot(p, filename)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"runtime"
+	"time"
+)
+
+type RunnerConfig struct {
+	warmUp        int
+	minSamples    int
+	maxSamples    int
+	targetRelCI   float64
+	timeBudget    time.Duration
+	minSampleTime time.Duration
+	profilePrefix string
+}
+
+var defaultRunnerConfig = RunnerConfig{
+	warmUp:        warmUpIterations,
+	minSamples:    10,
+	maxSamples:    1000,
+	targetRelCI:   0.02,
+	timeBudget:    2 * time.Second,
+	minSampleTime: 100 * time.Microsecond,
+}
+
+// runBenchmark prepares both operations for data and measures them one
+// after the other, each with half of the time budget.
+func runBenchmark(data []byte, measureFunc EncryptFunc, config RunnerConfig) (*BenchmarkResult, *BenchmarkResult) {
+	encrypt, decrypt := measureFunc(data)
+	config.timeBudget /= 2
+	return measureOperation(encrypt, config), measureOperation(decrypt, config)
+}
+
+// measureOperation keeps sampling until the 95% confidence interval of the
+// mean is narrower than targetRelCI (relative to the mean), or until the time
+// budget or sample limit is exhausted. Operations faster than minSampleTime
+// are batched: the whole batch is timed with a single pair of clock reads and
+// every sample is the mean of its batch, so timer overhead and resolution do
+// not dominate tiny payloads. The percentiles, extremes and raw samples are
+// therefore statistics of batch means, not of single operations.
+func measureOperation(op func(), config RunnerConfig) *BenchmarkResult {
+	for range config.warmUp {
+		op()
+	}
+
+	batchSize := calibrateBatchSize(op, config.minSampleTime)
+
+	// preallocated so that growing it does not show up as allocations
+	durations := make([]time.Duration, 0, config.maxSamples)
+	var stats runningStats
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	for len(durations) < config.maxSamples {
+		batchStart := time.Now()
+		for range batchSize {
+			op()
+		}
+		sample := time.Since(batchStart) / time.Duration(batchSize)
+
+		durations = append(durations, sample)
+		stats.add(float64(sample))
+
+		if len(durations) < config.minSamples {
+			continue
+		}
+		if stats.relativeCIWidth() <= config.targetRelCI {
+			break
+		}
+		if time.Since(start) >= config.timeBudget {
+			break
+		}
+	}
+
+	runtime.ReadMemStats(&after)
+
+	result := calculateBenchmarkResult(durations)
+	result.batchSize = batchSize
+	result.memory = newMemoryStats(&before, &after, len(durations)*batchSize)
+	return result
+}
+
+// calibrateBatchSize doubles the batch until a whole batch takes at least
+// minSampleTime, so the estimate is not itself dominated by the timer.
+func calibrateBatchSize(op func(), minSampleTime time.Duration) int {
+	for batchSize := 1; ; batchSize *= 2 {
+		start := time.Now()
+		for range batchSize {
+			op()
+		}
+		if time.Since(start) >= minSampleTime {
+			return batchSize
+		}
+	}
+}
+
+// runningStats implements Welford's online mean/variance so that the stopping
+// condition can be checked after every sample without re-scanning them.
+type runningStats struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+func (s *runningStats) add(x float64) {
+	s.n++
+	delta := x - s.mean
+	s.mean += delta / float64(s.n)
+	s.m2 += delta * (x - s.mean)
+}
+
+func (s *runningStats) relativeCIWidth() float64 {
+	if s.n < 2 || s.mean == 0 {
+		return math.Inf(1)
+	}
+	stddev := math.Sqrt(s.m2 / float64(s.n-1))
+	halfWidth := tCritical95(s.n-1) * stddev / math.Sqrt(float64(s.n))
+	return 2 * halfWidth / s.mean
+}
+
+// tCritical95 returns the two-sided 95% critical value of Student's
+// t-distribution for the given degrees of freedom.
+func tCritical95(df int) float64 {
+	table := []float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	switch {
+	case df < 1:
+		return math.Inf(1)
+	case df <= len(table):
+		return table[df-1]
+	case df <= 60:
+		return 2.000
+	case df <= 120:
+		return 1.980
+	default:
+		return 1.960
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+
+	"golang.org/x/sys/cpu"
+)
+
+const scratchDir = "results/scratch/"
+
+// ScratchCipher pairs a from-scratch cipher.Block with the stdlib one it is
+// benchmarked against. Both are checked against NIST known answers in
+// aes_test.go and des_test.go.
+type ScratchCipher struct {
+	name      string
+	file      string
+	kind      string
+	keySize   int
+	newCipher func([]byte) (cipher.Block, error)
+}
+
+func runScratchCiphers(args []string) {
+	fs := flag.NewFlagSet("scratch", flag.ExitOnError)
+	plotFormatFlag(fs)
+	fs.Parse(args)
+
+	fmt.Println("=== FROM-SCRATCH BLOCK CIPHERS ===")
+	fmt.Printf("crypto/aes uses AES instructions: %v\n\n", hasHardwareAES())
+
+	ciphers := []ScratchCipher{
+		{"AES-128-CBC (crypto/aes)", "aes128_stdlib", "aes128", 16, aes.NewCipher},
+		{"AES-128-CBC (table)", "aes128_table", "aes128", 16, newTableAES},
+		{"AES-128-CBC (bitsliced)", "aes128_bitsliced", "aes128", 16, newBitslicedAES},
+		{"AES-256-CBC (crypto/aes)", "aes256_stdlib", "aes256", 32, aes.NewCipher},
+		{"AES-256-CBC (table)", "aes256_table", "aes256", 32, newTableAES},
+		{"AES-256-CBC (bitsliced)", "aes256_bitsliced", "aes256", 32, newBitslicedAES},
+		{"3DES-CBC (crypto/des)", "3des192_stdlib", "des192", 24, des.NewTripleDESCipher},
+		{"3DES-CBC (scratch)", "3des192_scratch", "des192", 24, newScratchTripleDES},
+	}
+	dataSizes := []int{128, 2 * 1024, 32 * 1024, 256 * 1024, 1024 * 1024}
+
+	config := defaultRunnerConfig
+	for _, c := range ciphers {
+		fmt.Printf("Running benchmarks for %s...\n", c.name)
+		setup := func() (EncryptFunc, func()) { return setupEncryptCBC(c.kind, c.keySize, c.newCipher) }
+		config.profilePrefix = profilePrefix(scratchDir, c.name)
+		encrypt, decrypt := runDataSizesBenchmark(dataSizes, setup, config)
+		exportAER(encrypt, scratchDir+"encryption/"+c.file+".csv")
+		exportAER(decrypt, scratchDir+"decryption/"+c.file+".csv")
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		log.Fatalf("invalid test vector %q: %v", s, err)
+	}
+	return b
+}
+
+func hasHardwareAES() bool {
+	switch runtime.GOARCH {
+	case "amd64", "386":
+		return cpu.X86.HasAES
+	case "arm64":
+		return cpu.ARM64.HasAES
+	case "s390x":
+		return cpu.S390X.HasAES
+	}
+	return false
+}
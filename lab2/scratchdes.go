@@ -0,0 +1,243 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// ScratchDES follows FIPS 46-3 literally: every permutation is applied bit by
+// bit from the tables in the standard, which are numbered from 1 at the most
+// significant bit. crypto/des precomputes combined S-box/P tables instead.
+type ScratchDES struct {
+	subkeys [16]uint64
+}
+
+// ScratchTripleDES is TDEA in EDE mode (SP 800-67): E_K3(D_K2(E_K1(x))).
+type ScratchTripleDES struct {
+	c1, c2, c3 ScratchDES
+}
+
+var (
+	desInitialPermutation = []byte{
+		58, 50, 42, 34, 26, 18, 10, 2,
+		60, 52, 44, 36, 28, 20, 12, 4,
+		62, 54, 46, 38, 30, 22, 14, 6,
+		64, 56, 48, 40, 32, 24, 16, 8,
+		57, 49, 41, 33, 25, 17, 9, 1,
+		59, 51, 43, 35, 27, 19, 11, 3,
+		61, 53, 45, 37, 29, 21, 13, 5,
+		63, 55, 47, 39, 31, 23, 15, 7,
+	}
+	desFinalPermutation = invertPermutation(desInitialPermutation)
+
+	desExpansion = []byte{
+		32, 1, 2, 3, 4, 5,
+		4, 5, 6, 7, 8, 9,
+		8, 9, 10, 11, 12, 13,
+		12, 13, 14, 15, 16, 17,
+		16, 17, 18, 19, 20, 21,
+		20, 21, 22, 23, 24, 25,
+		24, 25, 26, 27, 28, 29,
+		28, 29, 30, 31, 32, 1,
+	}
+
+	desPermutation = []byte{
+		16, 7, 20, 21, 29, 12, 28, 17,
+		1, 15, 23, 26, 5, 18, 31, 10,
+		2, 8, 24, 14, 32, 27, 3, 9,
+		19, 13, 30, 6, 22, 11, 4, 25,
+	}
+
+	desPermutedChoice1 = []byte{
+		57, 49, 41, 33, 25, 17, 9,
+		1, 58, 50, 42, 34, 26, 18,
+		10, 2, 59, 51, 43, 35, 27,
+		19, 11, 3, 60, 52, 44, 36,
+		63, 55, 47, 39, 31, 23, 15,
+		7, 62, 54, 46, 38, 30, 22,
+		14, 6, 61, 53, 45, 37, 29,
+		21, 13, 5, 28, 20, 12, 4,
+	}
+
+	desPermutedChoice2 = []byte{
+		14, 17, 11, 24, 1, 5,
+		3, 28, 15, 6, 21, 10,
+		23, 19, 12, 4, 26, 8,
+		16, 7, 27, 20, 13, 2,
+		41, 52, 31, 37, 47, 55,
+		30, 40, 51, 45, 33, 48,
+		44, 49, 39, 56, 34, 53,
+		46, 42, 50, 36, 29, 32,
+	}
+
+	desKeyShifts = []int{1, 1, 2, 2, 2, 2, 2, 2, 1, 2, 2, 2, 2, 2, 2, 1}
+
+	// desSboxes[i][row][column], the row given by the outer two bits of the
+	// 6-bit input and the column by the inner four
+	desSboxes = [8][4][16]byte{
+		{
+			{14, 4, 13, 1, 2, 15, 11, 8, 3, 10, 6, 12, 5, 9, 0, 7},
+			{0, 15, 7, 4, 14, 2, 13, 1, 10, 6, 12, 11, 9, 5, 3, 8},
+			{4, 1, 14, 8, 13, 6, 2, 11, 15, 12, 9, 7, 3, 10, 5, 0},
+			{15, 12, 8, 2, 4, 9, 1, 7, 5, 11, 3, 14, 10, 0, 6, 13},
+		},
+		{
+			{15, 1, 8, 14, 6, 11, 3, 4, 9, 7, 2, 13, 12, 0, 5, 10},
+			{3, 13, 4, 7, 15, 2, 8, 14, 12, 0, 1, 10, 6, 9, 11, 5},
+			{0, 14, 7, 11, 10, 4, 13, 1, 5, 8, 12, 6, 9, 3, 2, 15},
+			{13, 8, 10, 1, 3, 15, 4, 2, 11, 6, 7, 12, 0, 5, 14, 9},
+		},
+		{
+			{10, 0, 9, 14, 6, 3, 15, 5, 1, 13, 12, 7, 11, 4, 2, 8},
+			{13, 7, 0, 9, 3, 4, 6, 10, 2, 8, 5, 14, 12, 11, 15, 1},
+			{13, 6, 4, 9, 8, 15, 3, 0, 11, 1, 2, 12, 5, 10, 14, 7},
+			{1, 10, 13, 0, 6, 9, 8, 7, 4, 15, 14, 3, 11, 5, 2, 12},
+		},
+		{
+			{7, 13, 14, 3, 0, 6, 9, 10, 1, 2, 8, 5, 11, 12, 4, 15},
+			{13, 8, 11, 5, 6, 15, 0, 3, 4, 7, 2, 12, 1, 10, 14, 9},
+			{10, 6, 9, 0, 12, 11, 7, 13, 15, 1, 3, 14, 5, 2, 8, 4},
+			{3, 15, 0, 6, 10, 1, 13, 8, 9, 4, 5, 11, 12, 7, 2, 14},
+		},
+		{
+			{2, 12, 4, 1, 7, 10, 11, 6, 8, 5, 3, 15, 13, 0, 14, 9},
+			{14, 11, 2, 12, 4, 7, 13, 1, 5, 0, 15, 10, 3, 9, 8, 6},
+			{4, 2, 1, 11, 10, 13, 7, 8, 15, 9, 12, 5, 6, 3, 0, 14},
+			{11, 8, 12, 7, 1, 14, 2, 13, 6, 15, 0, 9, 10, 4, 5, 3},
+		},
+		{
+			{12, 1, 10, 15, 9, 2, 6, 8, 0, 13, 3, 4, 14, 7, 5, 11},
+			{10, 15, 4, 2, 7, 12, 9, 5, 6, 1, 13, 14, 0, 11, 3, 8},
+			{9, 14, 15, 5, 2, 8, 12, 3, 7, 0, 4, 10, 1, 13, 11, 6},
+			{4, 3, 2, 12, 9, 5, 15, 10, 11, 14, 1, 7, 6, 0, 8, 13},
+		},
+		{
+			{4, 11, 2, 14, 15, 0, 8, 13, 3, 12, 9, 7, 5, 10, 6, 1},
+			{13, 0, 11, 7, 4, 9, 1, 10, 14, 3, 5, 12, 2, 15, 8, 6},
+			{1, 4, 11, 13, 12, 3, 7, 14, 10, 15, 6, 8, 0, 5, 9, 2},
+			{6, 11, 13, 8, 1, 4, 10, 7, 9, 5, 0, 15, 14, 2, 3, 12},
+		},
+		{
+			{13, 2, 8, 4, 6, 15, 11, 1, 10, 9, 3, 14, 5, 0, 12, 7},
+			{1, 15, 13, 8, 10, 3, 7, 4, 12, 5, 6, 11, 0, 14, 9, 2},
+			{7, 11, 4, 1, 9, 12, 14, 2, 0, 6, 10, 13, 15, 3, 5, 8},
+			{2, 1, 14, 7, 4, 10, 8, 13, 15, 12, 9, 0, 3, 5, 6, 11},
+		},
+	}
+)
+
+func invertPermutation(table []byte) []byte {
+	inverse := make([]byte, len(table))
+	for i, position := range table {
+		inverse[position-1] = byte(i + 1)
+	}
+	return inverse
+}
+
+// permute builds len(table) output bits; output bit i (from the most
+// significant end) is input bit table[i] of an inBits-wide value.
+func permute(in uint64, inBits int, table []byte) uint64 {
+	var out uint64
+	for _, position := range table {
+		out = out<<1 | in>>(inBits-int(position))&1
+	}
+	return out
+}
+
+func newScratchDES(key []byte) (cipher.Block, error) {
+	if len(key) != 8 {
+		return nil, fmt.Errorf("invalid DES key size %d", len(key))
+	}
+	c := &ScratchDES{}
+	c.expandKey(key)
+	return c, nil
+}
+
+func newScratchTripleDES(key []byte) (cipher.Block, error) {
+	if len(key) != 24 {
+		return nil, fmt.Errorf("invalid 3DES key size %d", len(key))
+	}
+	c := &ScratchTripleDES{}
+	c.c1.expandKey(key[0:8])
+	c.c2.expandKey(key[8:16])
+	c.c3.expandKey(key[16:24])
+	return c, nil
+}
+
+// expandKey drops the parity bits with PC-1, rotates both 28-bit halves
+// by the scheduled amount each round and selects 48 bits with PC-2.
+func (c *ScratchDES) expandKey(key []byte) {
+	cd := permute(binary.BigEndian.Uint64(key), 64, desPermutedChoice1)
+	left, right := cd>>28, cd&0x0fffffff
+	for round, shift := range desKeyShifts {
+		left = (left<<shift | left>>(28-shift)) & 0x0fffffff
+		right = (right<<shift | right>>(28-shift)) & 0x0fffffff
+		c.subkeys[round] = permute(left<<28|right, 56, desPermutedChoice2)
+	}
+}
+
+// desFeistel is f(R, K) = P(S(E(R) ^ K)).
+func desFeistel(right uint32, subkey uint64) uint32 {
+	x := permute(uint64(right), 32, desExpansion) ^ subkey
+
+	var s uint64
+	for i := range 8 {
+		six := x >> (42 - 6*i) & 0x3f
+		row := six>>4&2 | six&1
+		column := six >> 1 & 0xf
+		s = s<<4 | uint64(desSboxes[i][row][column])
+	}
+	return uint32(permute(s, 32, desPermutation))
+}
+
+func (c *ScratchDES) crypt(dst, src []byte, decrypt bool) {
+	block := permute(binary.BigEndian.Uint64(src), 64, desInitialPermutation)
+	left, right := uint32(block>>32), uint32(block)
+	for round := range 16 {
+		subkey := c.subkeys[round]
+		if decrypt {
+			subkey = c.subkeys[15-round]
+		}
+		left, right = right, left^desFeistel(right, subkey)
+	}
+	// the halves are swapped once more before the final permutation
+	block = uint64(right)<<32 | uint64(left)
+	binary.BigEndian.PutUint64(dst, permute(block, 64, desFinalPermutation))
+}
+
+func (c *ScratchDES) BlockSize() int { return 8 }
+
+func (c *ScratchDES) Encrypt(dst, src []byte) {
+	if len(src) < 8 || len(dst) < 8 {
+		panic("des: input not full block")
+	}
+	c.crypt(dst, src, false)
+}
+
+func (c *ScratchDES) Decrypt(dst, src []byte) {
+	if len(src) < 8 || len(dst) < 8 {
+		panic("des: input not full block")
+	}
+	c.crypt(dst, src, true)
+}
+
+func (c *ScratchTripleDES) BlockSize() int { return 8 }
+
+func (c *ScratchTripleDES) Encrypt(dst, src []byte) {
+	if len(src) < 8 || len(dst) < 8 {
+		panic("des: input not full block")
+	}
+	c.c1.crypt(dst, src, false)
+	c.c2.crypt(dst, dst, true)
+	c.c3.crypt(dst, dst, false)
+}
+
+func (c *ScratchTripleDES) Decrypt(dst, src []byte) {
+	if len(src) < 8 || len(dst) < 8 {
+		panic("des: input not full block")
+	}
+	c.c3.crypt(dst, src, true)
+	c.c2.crypt(dst, dst, false)
+	c.c1.crypt(dst, dst, true)
+}
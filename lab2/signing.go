@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"log"
+
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+)
+
+func benchmarkSigning() map[string]struct {
+	sign   []*AlgorithmEncryptResult
+	verify []*AlgorithmEncryptResult
+} {
+	config := defaultRunnerConfig
+
+	messageSizes := []int{128, 512, 2 * 1024, 8 * 1024, 32 * 1024, 1024 * 1024, 4 * 1024 * 1024, 16 * 1024 * 1024}
+
+	algorithms := map[string]SetupFunc{
+		"RSA-PSS-2048":      func() (EncryptFunc, func()) { return setupSignRSAPSS(2048) },
+		"RSA-PKCS1v15-2048": func() (EncryptFunc, func()) { return setupSignRSAPKCS1v15(2048) },
+		"ECDSA-P256":        func() (EncryptFunc, func()) { return setupSignECDSA(elliptic.P256()) },
+		"Ed25519":           setupSignEd25519,
+		"ML-DSA-65":         setupSignMLDSA65,
+	}
+
+	results := make(map[string]struct {
+		sign   []*AlgorithmEncryptResult
+		verify []*AlgorithmEncryptResult
+	})
+
+	for algoName, setup := range algorithms {
+		fmt.Printf("Running benchmarks for %s...\n", algoName)
+
+		config.profilePrefix = profilePrefix("results/signing", algoName)
+		signResults, verifyResults := runDataSizesBenchmark(messageSizes, setup, config)
+		results[algoName] = struct {
+			sign   []*AlgorithmEncryptResult
+			verify []*AlgorithmEncryptResult
+		}{signResults, verifyResults}
+	}
+
+	return results
+}
+
+func setupSignRSAPSS(bits int) (EncryptFunc, func()) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		log.Fatalf("Error generating RSA key: %v", err)
+	}
+
+	return func(data []byte) (func(), func()) {
+		sign := func() []byte {
+			digest := sha256.Sum256(data)
+			signature, err := rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, digest[:], nil)
+			if err != nil {
+				log.Fatalf("Error signing RSA-PSS: %v", err)
+			}
+			return signature
+		}
+		signature := sign()
+
+		return func() { sign() }, func() {
+			digest := sha256.Sum256(data)
+			if err := rsa.VerifyPSS(&privateKey.PublicKey, crypto.SHA256, digest[:], signature, nil); err != nil {
+				log.Fatalf("Error verifying RSA-PSS: %v", err)
+			}
+		}
+	}, func() {}
+}
+
+func setupSignRSAPKCS1v15(bits int) (EncryptFunc, func()) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		log.Fatalf("Error generating RSA key: %v", err)
+	}
+
+	return func(data []byte) (func(), func()) {
+		sign := func() []byte {
+			digest := sha256.Sum256(data)
+			signature, err := rsa.SignPKCS1v15(nil, privateKey, crypto.SHA256, digest[:])
+			if err != nil {
+				log.Fatalf("Error signing RSA PKCS#1 v1.5: %v", err)
+			}
+			return signature
+		}
+		signature := sign()
+
+		return func() { sign() }, func() {
+			digest := sha256.Sum256(data)
+			if err := rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				log.Fatalf("Error verifying RSA PKCS#1 v1.5: %v", err)
+			}
+		}
+	}, func() {}
+}
+
+func setupSignECDSA(curve elliptic.Curve) (EncryptFunc, func()) {
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		log.Fatalf("Error generating ECDSA key: %v", err)
+	}
+
+	return func(data []byte) (func(), func()) {
+		sign := func() []byte {
+			digest := sha256.Sum256(data)
+			signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+			if err != nil {
+				log.Fatalf("Error signing ECDSA: %v", err)
+			}
+			return signature
+		}
+		signature := sign()
+
+		return func() { sign() }, func() {
+			digest := sha256.Sum256(data)
+			if !ecdsa.VerifyASN1(&privateKey.PublicKey, digest[:], signature) {
+				log.Fatalf("Error verifying ECDSA: invalid signature")
+			}
+		}
+	}, func() {}
+}
+
+func setupSignEd25519() (EncryptFunc, func()) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Error generating Ed25519 key: %v", err)
+	}
+
+	return func(data []byte) (func(), func()) {
+		signature := ed25519.Sign(privateKey, data)
+
+		return func() { ed25519.Sign(privateKey, data) }, func() {
+			if !ed25519.Verify(publicKey, data, signature) {
+				log.Fatalf("Error verifying Ed25519: invalid signature")
+			}
+		}
+	}, func() {}
+}
+
+func setupSignMLDSA65() (EncryptFunc, func()) {
+	publicKey, privateKey, err := mldsa65.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Error generating ML-DSA-65 key: %v", err)
+	}
+
+	return func(data []byte) (func(), func()) {
+		signature := make([]byte, mldsa65.SignatureSize)
+		sign := func() {
+			if err := mldsa65.SignTo(privateKey, data, nil, true, signature); err != nil {
+				log.Fatalf("Error signing ML-DSA-65: %v", err)
+			}
+		}
+		sign()
+
+		return sign, func() {
+			if !mldsa65.Verify(publicKey, data, nil, signature) {
+				log.Fatalf("Error verifying ML-DSA-65: invalid signature")
+			}
+		}
+	}, func() {}
+}
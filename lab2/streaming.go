@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChunkFunc transforms one chunk of a stream. The last chunk is flagged so
+// that constructions can finalize (STREAM last-segment nonce, CBC padding).
+// The returned slice may alias dst and is only valid until the next call.
+type ChunkFunc func(dst, chunk []byte, last bool) ([]byte, error)
+
+// StreamSetupFunc creates a fresh key and the encrypt/decrypt chunk
+// transforms of one stream.
+type StreamSetupFunc func() (ChunkFunc, ChunkFunc)
+
+type StreamResult struct {
+	chunkSize   int
+	totalBytes  int64
+	chunks      int
+	encryptTime time.Duration
+	decryptTime time.Duration
+	allocs      uint64
+	allocBytes  uint64
+}
+
+const streamNoncePrefixSize = 7
+
+func runStreamingBenchmarks(args []string) {
+	fs := flag.NewFlagSet("stream", flag.ExitOnError)
+	plotFormatFlag(fs)
+	sizeMiB := fs.Int64("size", 256, "stream size in MiB, ignored with -file")
+	chunkList := fs.String("chunks", "4096,16384,65536,262144,1048576,4194304", "comma separated chunk sizes in bytes")
+	inputFile := fs.String("file", "", "encrypt this file instead of a generated stream")
+	fs.Parse(args)
+
+	chunkSizes, err := parseChunkSizes(*chunkList)
+	if err != nil {
+		log.Fatalf("invalid -chunks: %v", err)
+	}
+
+	algorithms := map[string]StreamSetupFunc{
+		"AES-128-GCM-STREAM": func() (ChunkFunc, ChunkFunc) { return setupStreamAESGCM(128) },
+		"AES-256-GCM-STREAM": func() (ChunkFunc, ChunkFunc) { return setupStreamAESGCM(256) },
+		"AES-256-CBC":        setupStreamAESCBC,
+		"AES-256-CTR":        setupStreamAESCTR,
+	}
+	files := map[string]string{
+		"AES-128-GCM-STREAM": "results/streaming/aes128gcm_stream.csv",
+		"AES-256-GCM-STREAM": "results/streaming/aes256gcm_stream.csv",
+		"AES-256-CBC":        "results/streaming/aes256cbc.csv",
+		"AES-256-CTR":        "results/streaming/aes256ctr.csv",
+	}
+
+	fmt.Println("=== STREAMING ENCRYPTION BENCHMARKS ===")
+
+	for algoName, setup := range algorithms {
+		fmt.Printf("Running benchmarks for %s...\n", algoName)
+
+		results := make([]*StreamResult, 0, len(chunkSizes))
+		for _, chunkSize := range chunkSizes {
+			src, closeSrc := openStreamSource(*inputFile, *sizeMiB*mbDivider)
+			encryptChunk, decryptChunk := setup()
+
+			result, err := measureStream(src, chunkSize, encryptChunk, decryptChunk)
+			closeSrc()
+			if err != nil {
+				log.Fatalf("Error streaming %s with %d byte chunks: %v", algoName, chunkSize, err)
+			}
+
+			fmt.Printf("  chunk %d bytes: %.2f MB/s encrypt, %.2f MB/s decrypt, %.2f allocs/chunk\n",
+				chunkSize, streamThroughput(result.totalBytes, result.encryptTime),
+				streamThroughput(result.totalBytes, result.decryptTime), float64(result.allocs)/float64(result.chunks))
+			results = append(results, result)
+		}
+
+		exportStream(results, files[algoName])
+	}
+}
+
+func parseChunkSizes(list string) ([]int, error) {
+	sizes := []int{}
+	for _, field := range strings.Split(list, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		// CBC needs block-aligned chunks for everything but the last one
+		if size <= 0 || size%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("chunk size %d is not a positive multiple of %d", size, aes.BlockSize)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// openStreamSource opens the input file, or generates size bytes by
+// repeating a random buffer so multi-GiB streams need no memory.
+func openStreamSource(filename string, size int64) (io.Reader, func()) {
+	if filename != "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			log.Fatalf("Error opening %s: %v", filename, err)
+		}
+		return f, func() { f.Close() }
+	}
+
+	pattern := make([]byte, mbDivider)
+	if _, err := rand.Read(pattern); err != nil {
+		log.Fatalf("Error generating random data: %v", err)
+	}
+	return io.LimitReader(&repeatingReader{pattern: pattern}, size), func() {}
+}
+
+type repeatingReader struct {
+	pattern []byte
+	offset  int
+}
+
+func (r *repeatingReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], r.pattern[r.offset:])
+		n += copied
+		r.offset = (r.offset + copied) % len(r.pattern)
+	}
+	return n, nil
+}
+
+// measureStream pipes the source through encryption and straight back
+// through decryption chunk by chunk, timing both transforms separately and
+// checking the round trip. Reading the source is not timed. A stream whose
+// length is a multiple of the chunk size ends with an empty last chunk.
+func measureStream(src io.Reader, chunkSize int, encryptChunk, decryptChunk ChunkFunc) (*StreamResult, error) {
+	plaintext := make([]byte, chunkSize)
+	ciphertext := make([]byte, 0, chunkSize+aes.BlockSize)
+	decrypted := make([]byte, 0, chunkSize+aes.BlockSize)
+
+	result := &StreamResult{chunkSize: chunkSize}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	for last := false; !last; {
+		n, err := io.ReadFull(src, plaintext)
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			last = true
+		case err != nil:
+			return nil, err
+		}
+		chunk := plaintext[:n]
+
+		start := time.Now()
+		sealed, err := encryptChunk(ciphertext[:0], chunk, last)
+		result.encryptTime += time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+
+		start = time.Now()
+		opened, err := decryptChunk(decrypted[:0], sealed, last)
+		result.decryptTime += time.Since(start)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(opened, chunk) {
+			return nil, fmt.Errorf("chunk %d does not round-trip", result.chunks)
+		}
+		result.totalBytes += int64(n)
+		result.chunks++
+	}
+
+	runtime.ReadMemStats(&after)
+	result.allocs = after.Mallocs - before.Mallocs
+	result.allocBytes = after.TotalAlloc - before.TotalAlloc
+
+	return result, nil
+}
+
+// setupStreamAESGCM runs the STREAM construction over AES-GCM with a fresh
+// key and nonce prefix.
+func setupStreamAESGCM(keySizeBits int) (ChunkFunc, ChunkFunc) {
+	key := make([]byte, keySizeBits/8)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Error generating AES key: %v", err)
+	}
+	prefix := make([]byte, streamNoncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		log.Fatalf("Error generating nonce prefix: %v", err)
+	}
+	return newStreamAEAD(newAESGCM(key), prefix)
+}
+
+// newStreamAEAD implements the STREAM construction (Hoang et al.) over any
+// AEAD: every chunk is sealed under the nonce
+// prefix || chunk counter (4 bytes) || last chunk flag (1 byte),
+// which prevents reordering, dropping and truncating chunks. Nothing is
+// accepted after the last chunk. The prefix must be 5 bytes shorter than the
+// AEAD nonce (7 bytes for GCM).
+func newStreamAEAD(aead cipher.AEAD, prefix []byte) (ChunkFunc, ChunkFunc) {
+	newStreamNonce := func() func(last bool) ([]byte, error) {
+		nonce := make([]byte, aead.NonceSize())
+		copy(nonce, prefix)
+		var counter uint64
+		finished := false
+		return func(last bool) ([]byte, error) {
+			if finished {
+				return nil, errors.New("chunk after the last one")
+			}
+			if counter > 0xFFFFFFFF {
+				return nil, fmt.Errorf("stream too long: chunk counter overflow")
+			}
+			finished = last
+			binary.BigEndian.PutUint32(nonce[len(prefix):], uint32(counter))
+			nonce[len(nonce)-1] = 0
+			if last {
+				nonce[len(nonce)-1] = 1
+			}
+			counter++
+			return nonce, nil
+		}
+	}
+
+	sealNonce, openNonce := newStreamNonce(), newStreamNonce()
+
+	encrypt := func(dst, chunk []byte, last bool) ([]byte, error) {
+		nonce, err := sealNonce(last)
+		if err != nil {
+			return nil, err
+		}
+		return aead.Seal(dst, nonce, chunk, nil), nil
+	}
+	decrypt := func(dst, chunk []byte, last bool) ([]byte, error) {
+		nonce, err := openNonce(last)
+		if err != nil {
+			return nil, err
+		}
+		return aead.Open(dst, nonce, chunk, nil)
+	}
+	return encrypt, decrypt
+}
+
+// setupStreamAESCBC chains CBC across chunks and pads only the last one.
+func setupStreamAESCBC() (ChunkFunc, ChunkFunc) {
+	block, iv := newStreamAESBlock()
+	encrypter := cipher.NewCBCEncrypter(block, iv)
+	decrypter := cipher.NewCBCDecrypter(block, iv)
+
+	encrypt := func(dst, chunk []byte, last bool) ([]byte, error) {
+		dst = append(dst, chunk...)
+		if last {
+			dst = pkcs7Pad(dst, aes.BlockSize)
+		}
+		encrypter.CryptBlocks(dst, dst)
+		return dst, nil
+	}
+	decrypt := func(dst, chunk []byte, last bool) ([]byte, error) {
+		if len(chunk)%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("ciphertext chunk is not block aligned")
+		}
+		dst = append(dst, chunk...)
+		decrypter.CryptBlocks(dst, dst)
+		if last {
+			return pkcs7Unpad(dst, aes.BlockSize)
+		}
+		return dst, nil
+	}
+	return encrypt, decrypt
+}
+
+func setupStreamAESCTR() (ChunkFunc, ChunkFunc) {
+	block, iv := newStreamAESBlock()
+	encrypter := cipher.NewCTR(block, iv)
+	decrypter := cipher.NewCTR(block, iv)
+
+	transform := func(stream cipher.Stream) ChunkFunc {
+		return func(dst, chunk []byte, last bool) ([]byte, error) {
+			dst = append(dst, chunk...)
+			stream.XORKeyStream(dst, dst)
+			return dst, nil
+		}
+	}
+	return transform(encrypter), transform(decrypter)
+}
+
+func newStreamAESBlock() (cipher.Block, []byte) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Error generating AES key: %v", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		log.Fatalf("Error generating IV: %v", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		log.Fatalf("Error creating AES cipher: %v", err)
+	}
+	return block, iv
+}
+
+func streamThroughput(totalBytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(totalBytes) / mbDivider / elapsed.Seconds()
+}
+
+func exportStream(results []*StreamResult, filepath string) {
+	records := [][]string{{
+		"Chunk size", "Total bytes", "Chunks", "Encrypt time (ns)", "Decrypt time (ns)",
+		"Encrypt throughput (MB/s)", "Decrypt throughput (MB/s)", "Allocs", "Alloc bytes", "Allocs per chunk",
+	}}
+	for _, result := range results {
+		records = append(records, []string{
+			fmt.Sprint(result.chunkSize),
+			fmt.Sprint(result.totalBytes),
+			fmt.Sprint(result.chunks),
+			fmt.Sprint(result.encryptTime.Nanoseconds()),
+			fmt.Sprint(result.decryptTime.Nanoseconds()),
+			fmt.Sprintf("%.4f", streamThroughput(result.totalBytes, result.encryptTime)),
+			fmt.Sprintf("%.4f", streamThroughput(result.totalBytes, result.decryptTime)),
+			fmt.Sprint(result.allocs),
+			fmt.Sprint(result.allocBytes),
+			fmt.Sprintf("%.4f", float64(result.allocs)/float64(result.chunks)),
+		})
+	}
+	exportToCSV(filepath, records)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"slices"
+	"testing"
+)
+
+func TestStreamAEAD(t *testing.T) {
+	aeads := map[string]cipher.AEAD{
+		"AES-GCM": newAESGCM(bytes.Repeat([]byte{1}, 32)),
+	}
+	cbc, err := newFile3DESCBC(bytes.Repeat([]byte{2}, 24+32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aeads["3DES-CBC-HMAC"] = cbc
+
+	plaintexts := [][]byte{[]byte("first chunk"), []byte("second chunk"), []byte("third chunk"), {}}
+
+	for name, aead := range aeads {
+		prefix := bytes.Repeat([]byte{3}, aead.NonceSize()-5)
+		encrypt, _ := newStreamAEAD(aead, prefix)
+		sealed := make([][]byte, len(plaintexts))
+		for i, p := range plaintexts {
+			sealed[i], err = encrypt(nil, p, i == len(plaintexts)-1)
+			if err != nil {
+				t.Fatalf("%s: sealing chunk %d: %v", name, i, err)
+			}
+		}
+
+		tampered := slices.Clone(sealed)
+		tampered[1] = slices.Clone(sealed[1])
+		tampered[1][0] ^= 1
+
+		cases := []struct {
+			name   string
+			chunks [][]byte
+			ok     bool
+		}{
+			{"round trip", sealed, true},
+			{"truncated", sealed[:len(sealed)-1], false},
+			{"reordered", [][]byte{sealed[1], sealed[0], sealed[2], sealed[3]}, false},
+			{"dropped", [][]byte{sealed[0], sealed[2], sealed[3]}, false},
+			{"trailing", append(slices.Clone(sealed), sealed[3]), false},
+			{"modified", tampered, false},
+		}
+		for _, c := range cases {
+			t.Run(name+"/"+c.name, func(t *testing.T) {
+				_, decrypt := newStreamAEAD(aead, prefix)
+				var opened [][]byte
+				var err error
+				for i, chunk := range c.chunks {
+					var p []byte
+					p, err = decrypt(nil, chunk, i == len(c.chunks)-1)
+					if err != nil {
+						break
+					}
+					opened = append(opened, p)
+				}
+
+				switch {
+				case c.ok && err != nil:
+					t.Fatalf("decrypt failed: %v", err)
+				case c.ok && !slices.EqualFunc(opened, plaintexts, bytes.Equal):
+					t.Fatalf("decrypt = %q, want %q", opened, plaintexts)
+				case !c.ok && err == nil:
+					t.Fatalf("decrypt accepted the stream")
+				}
+			})
+		}
+	}
+}
+
+// TestStreamAEADFinished checks that decrypting stops at the last chunk even
+// when the caller does not.
+func TestStreamAEADFinished(t *testing.T) {
+	aead := newAESGCM(make([]byte, 16))
+	encrypt, decrypt := newStreamAEAD(aead, make([]byte, streamNoncePrefixSize))
+	sealed, err := encrypt(nil, []byte("only chunk"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encrypt(nil, []byte("more"), true); err == nil {
+		t.Error("encrypt accepted a chunk after the last one")
+	}
+	if _, err := decrypt(nil, sealed, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(nil, sealed, true); err == nil {
+		t.Error("decrypt accepted a chunk after the last one")
+	}
+}
+
+func TestPKCS7(t *testing.T) {
+	pads := []struct {
+		data   string
+		padded string
+	}{
+		{"", "\x08\x08\x08\x08\x08\x08\x08\x08"},
+		{"a", "a\x07\x07\x07\x07\x07\x07\x07"},
+		{"abcdefg", "abcdefg\x01"},
+		{"abcdefgh", "abcdefgh\x08\x08\x08\x08\x08\x08\x08\x08"},
+		{"abcdefghi", "abcdefghi\x07\x07\x07\x07\x07\x07\x07"},
+	}
+	for _, c := range pads {
+		padded := pkcs7Pad([]byte(c.data), 8)
+		if string(padded) != c.padded {
+			t.Errorf("pkcs7Pad(%q) = %q, want %q", c.data, padded, c.padded)
+		}
+		data, err := pkcs7Unpad(padded, 8)
+		if err != nil || string(data) != c.data {
+			t.Errorf("pkcs7Unpad(%q) = %q, %v, want %q", padded, data, err, c.data)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"abcdefg\x00",
+		"abcdefg\x09",
+		"abcdef\x01\x02",
+		"abcde\x02\x03\x03",
+		"\x04\x04\x04",
+	}
+	for _, padded := range invalid {
+		if data, err := pkcs7Unpad([]byte(padded), 8); err == nil {
+			t.Errorf("pkcs7Unpad(%q) = %q, want an error", padded, data)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"log"
+	"math/big"
+)
+
+// toyMillerRabinRounds gives an error probability below 4^-20 for any input,
+// far more than random candidates need.
+const toyMillerRabinRounds = 20
+
+// ToyRSAKey is a two-prime RSA key built with math/big only. Nothing here is
+// constant time; it exists to show the arithmetic and to be compared with
+// crypto/rsa, not to protect anything.
+type ToyRSAKey struct {
+	n, e, d *big.Int
+	p, q    *big.Int
+	// CRT parameters: d mod (p-1), d mod (q-1) and q^-1 mod p
+	dp, dq, qinv *big.Int
+}
+
+var (
+	bigOne = big.NewInt(1)
+	bigTwo = big.NewInt(2)
+)
+
+var errToyDecryption = errors.New("toy rsa: decryption error")
+
+// millerRabin reports whether n is probably prime, testing random bases.
+func millerRabin(n *big.Int, rounds int) (bool, error) {
+	if n.Cmp(bigTwo) < 0 {
+		return false, nil
+	}
+	if n.Bit(0) == 0 {
+		return n.Cmp(bigTwo) == 0, nil
+	}
+	if n.Cmp(big.NewInt(3)) == 0 {
+		return true, nil
+	}
+
+	// n - 1 = 2^s * r with r odd
+	nMinusOne := new(big.Int).Sub(n, bigOne)
+	s := nMinusOne.TrailingZeroBits()
+	r := new(big.Int).Rsh(nMinusOne, s)
+
+	// bases are drawn from [2, n-2]
+	baseRange := new(big.Int).Sub(n, big.NewInt(3))
+	x := new(big.Int)
+	for range rounds {
+		a, err := rand.Int(rand.Reader, baseRange)
+		if err != nil {
+			return false, err
+		}
+		a.Add(a, bigTwo)
+
+		x.Exp(a, r, n)
+		if x.Cmp(bigOne) == 0 || x.Cmp(nMinusOne) == 0 {
+			continue
+		}
+		witness := true
+		for range s - 1 {
+			x.Mul(x, x).Mod(x, n)
+			if x.Cmp(nMinusOne) == 0 {
+				witness = false
+				break
+			}
+		}
+		if witness {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// toyGeneratePrime returns a random prime of exactly bits bits with the top
+// two bits set, so the product of two of them has twice the length, and with
+// gcd(p-1, e) = 1 so that e is invertible.
+func toyGeneratePrime(bits int, e *big.Int) (*big.Int, error) {
+	gcd := new(big.Int)
+	return searchPrime(bits, func(candidate *big.Int) (bool, error) {
+		if gcd.GCD(nil, nil, new(big.Int).Sub(candidate, bigOne), e).Cmp(bigOne) != 0 {
+			return false, nil
+		}
+		return millerRabin(candidate, toyMillerRabinRounds)
+	}, nil)
+}
+
+func toyGenerateKey(bits int, e int64) (*ToyRSAKey, error) {
+	exponent := big.NewInt(e)
+	for {
+		p, err := toyGeneratePrime(bits-bits/2, exponent)
+		if err != nil {
+			return nil, err
+		}
+		q, err := toyGeneratePrime(bits/2, exponent)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		key, err := toyKeyFromPrimes(p, q, exponent)
+		if err != nil {
+			return nil, err
+		}
+		if key.n.BitLen() == bits {
+			return key, nil
+		}
+	}
+}
+
+// toyKeyFromPrimes derives d = e^-1 mod (p-1)(q-1) and the CRT parameters.
+func toyKeyFromPrimes(p, q, e *big.Int) (*ToyRSAKey, error) {
+	pMinusOne := new(big.Int).Sub(p, bigOne)
+	qMinusOne := new(big.Int).Sub(q, bigOne)
+	phi := new(big.Int).Mul(pMinusOne, qMinusOne)
+
+	d := new(big.Int).ModInverse(e, phi)
+	if d == nil {
+		return nil, fmt.Errorf("e = %v is not invertible mod phi(n)", e)
+	}
+
+	return &ToyRSAKey{
+		n:    new(big.Int).Mul(p, q),
+		e:    new(big.Int).Set(e),
+		d:    d,
+		p:    p,
+		q:    q,
+		dp:   new(big.Int).Mod(d, pMinusOne),
+		dq:   new(big.Int).Mod(d, qMinusOne),
+		qinv: new(big.Int).ModInverse(q, p),
+	}, nil
+}
+
+func (k *ToyRSAKey) size() int {
+	return (k.n.BitLen() + 7) / 8
+}
+
+// toRSA converts the key so that crypto/rsa can use it, which checks that both
+// implementations agree on the same key material.
+func (k *ToyRSAKey) toRSA() (*rsa.PrivateKey, error) {
+	if !k.e.IsInt64() || k.e.Int64() > 1<<31-1 {
+		return nil, errors.New("public exponent too large for crypto/rsa")
+	}
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: k.n, E: int(k.e.Int64())},
+		D:         k.d,
+		Primes:    []*big.Int{k.p, k.q},
+	}
+	key.Precompute()
+	return key, key.Validate()
+}
+
+// toyEncryptTextbook computes m^e mod n with no padding: deterministic,
+// malleable and open to every attack in rsaattacks.go.
+func (k *ToyRSAKey) toyEncryptTextbook(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, k.e, k.n)
+}
+
+func (k *ToyRSAKey) toyDecryptTextbook(c *big.Int) *big.Int {
+	return new(big.Int).Exp(c, k.d, k.n)
+}
+
+// toyDecryptCRT computes c^d mod n from two half-size exponentiations and
+// Garner's recombination, which is about four times cheaper.
+func (k *ToyRSAKey) toyDecryptCRT(c *big.Int) *big.Int {
+	m1 := new(big.Int).Exp(c, k.dp, k.p)
+	m2 := new(big.Int).Exp(c, k.dq, k.q)
+
+	h := new(big.Int).Sub(m1, m2)
+	h.Mul(h, k.qinv).Mod(h, k.p)
+	return h.Mul(h, k.q).Add(h, m2)
+}
+
+// toyEncryptOAEP follows RSAES-OAEP from RFC 8017 section 7.1.1 with
+// SHA-256 and MGF1-SHA-256, so its ciphertexts decrypt with rsa.DecryptOAEP.
+func (k *ToyRSAKey) toyEncryptOAEP(message, label []byte) ([]byte, error) {
+	h := sha256.New()
+	hLen := h.Size()
+	size := k.size()
+	if len(message) > size-2*hLen-2 {
+		return nil, errors.New("toy rsa: message too long")
+	}
+
+	h.Write(label)
+	lHash := h.Sum(nil)
+
+	// EM = 0x00 || maskedSeed || maskedDB, DB = lHash || PS || 0x01 || M
+	em := make([]byte, size)
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+	copy(db, lHash)
+	db[len(db)-len(message)-1] = 0x01
+	copy(db[len(db)-len(message):], message)
+
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	mgf1XOR(db, h, seed)
+	mgf1XOR(seed, h, db)
+
+	c := k.toyEncryptTextbook(new(big.Int).SetBytes(em))
+	return c.FillBytes(make([]byte, size)), nil
+}
+
+func (k *ToyRSAKey) toyDecryptOAEP(ciphertext, label []byte, crt bool) ([]byte, error) {
+	h := sha256.New()
+	hLen := h.Size()
+	size := k.size()
+	if len(ciphertext) != size || size < 2*hLen+2 {
+		return nil, errToyDecryption
+	}
+
+	c := new(big.Int).SetBytes(ciphertext)
+	if c.Cmp(k.n) >= 0 {
+		return nil, errToyDecryption
+	}
+	var m *big.Int
+	if crt {
+		m = k.toyDecryptCRT(c)
+	} else {
+		m = k.toyDecryptTextbook(c)
+	}
+
+	em := m.FillBytes(make([]byte, size))
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+	mgf1XOR(seed, h, db)
+	mgf1XOR(db, h, seed)
+
+	h.Write(label)
+	lHash := h.Sum(nil)
+
+	// a real implementation must not reveal which check failed (Manger's
+	// attack); the toy at least returns one error for all of them
+	if em[0] != 0 || subtle.ConstantTimeCompare(db[:hLen], lHash) != 1 {
+		return nil, errToyDecryption
+	}
+	rest := db[hLen:]
+	separator := bytes.IndexByte(rest, 0x01)
+	if separator < 0 || !allZero(rest[:separator]) {
+		return nil, errToyDecryption
+	}
+	return rest[separator+1:], nil
+}
+
+// mgf1XOR XORs out with MGF1(seed) as defined in RFC 8017 appendix B.2.1.
+func mgf1XOR(out []byte, h hash.Hash, seed []byte) {
+	counter := make([]byte, 4)
+	for done := 0; done < len(out); {
+		h.Reset()
+		h.Write(seed)
+		h.Write(counter)
+		for _, b := range h.Sum(nil) {
+			if done == len(out) {
+				break
+			}
+			out[done] ^= b
+			done++
+		}
+		incrementCounter(counter)
+	}
+	h.Reset()
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func setupEncryptToyRSA(bits int, crt bool) (EncryptFunc, func()) {
+	key, err := toyGenerateKey(bits, 65537)
+	if err != nil {
+		log.Fatalf("Error generating toy RSA key: %v", err)
+	}
+
+	return func(data []byte) (func(), func()) {
+		encrypt := func() []byte {
+			ciphertext, err := key.toyEncryptOAEP(data, nil)
+			if err != nil {
+				log.Fatalf("Error encrypting: %v", err)
+			}
+			return ciphertext
+		}
+		ciphertext := encrypt()
+
+		return func() { encrypt() }, func() {
+			if _, err := key.toyDecryptOAEP(ciphertext, nil, crt); err != nil {
+				log.Fatalf("Error decrypting: %v", err)
+			}
+		}
+	}, func() {}
+}
+
+// runToyRSA checks the toy implementation against crypto/rsa in both
+// directions, compares key generation and decryption with and without CRT,
+// and then runs the attack demos.
+func runToyRSA(args []string) {
+	fs := flag.NewFlagSet("toy-rsa", flag.ExitOnError)
+	bits := fs.Int("bits", 2048, "modulus size")
+	keys := fs.Int("keys", 5, "keys generated per implementation for the key generation comparison")
+	fs.Parse(args)
+
+	fmt.Println("=== TOY RSA (math/big) ===")
+
+	key, err := toyGenerateKey(*bits, 65537)
+	if err != nil {
+		log.Fatalf("Error generating toy RSA key: %v", err)
+	}
+	stdKey, err := key.toRSA()
+	if err != nil {
+		log.Fatalf("crypto/rsa rejected the toy key: %v", err)
+	}
+
+	message := []byte("toy RSA interoperates with crypto/rsa")
+	ciphertext, err := key.toyEncryptOAEP(message, nil)
+	if err != nil {
+		log.Fatalf("Error encrypting: %v", err)
+	}
+	if plaintext, err := rsa.DecryptOAEP(sha256.New(), nil, stdKey, ciphertext, nil); err != nil || !bytes.Equal(plaintext, message) {
+		log.Fatalf("crypto/rsa could not decrypt toy OAEP: %v", err)
+	}
+	ciphertext, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, &stdKey.PublicKey, message, nil)
+	if err != nil {
+		log.Fatalf("Error encrypting: %v", err)
+	}
+	for _, crt := range []bool{false, true} {
+		if plaintext, err := key.toyDecryptOAEP(ciphertext, nil, crt); err != nil || !bytes.Equal(plaintext, message) {
+			log.Fatalf("toy RSA could not decrypt crypto/rsa OAEP (crt %v): %v", crt, err)
+		}
+	}
+	fmt.Println("OAEP ciphertexts decrypt across both implementations")
+
+	toyKeyGen, _ := measureKeyGen(fmt.Sprintf("toyrsa%d", *bits), *keys, func() (any, error) { return toyGenerateKey(*bits, 65537) })
+	stdKeyGen, _ := measureTimeRSA(*keys, *bits)
+	fmt.Printf("Key generation (%d keys): toy mean %v, crypto/rsa mean %v\n",
+		*keys, calculateBenchmarkResult(toyKeyGen).mean, calculateBenchmarkResult(stdKeyGen).mean)
+
+	c := new(big.Int).SetBytes(ciphertext)
+	decryptions := []struct {
+		name    string
+		decrypt func()
+	}{
+		{"toy textbook c^d mod n", func() { key.toyDecryptTextbook(c) }},
+		{"toy CRT", func() { key.toyDecryptCRT(c) }},
+		{"crypto/rsa OAEP", func() { rsa.DecryptOAEP(sha256.New(), nil, stdKey, ciphertext, nil) }},
+	}
+	for _, d := range decryptions {
+		result := measureOperation(d.decrypt, defaultRunnerConfig)
+		fmt.Printf("Decryption, %s: median %v\n", d.name, result.median)
+	}
+
+	fmt.Println()
+	runRSAAttacks(*bits)
+}
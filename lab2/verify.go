@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// WycheproofFile holds the fields every Wycheproof vector file shares; the
+// test groups are decoded once the algorithm is known. CAVP vectors are
+// converted to the same layout so that one loader handles both.
+type WycheproofFile struct {
+	Algorithm  string            `json:"algorithm"`
+	Schema     string            `json:"schema"`
+	Header     []string          `json:"header"`
+	TestGroups []json.RawMessage `json:"testGroups"`
+}
+
+type WycheproofVector struct {
+	TcID    int      `json:"tcId"`
+	Comment string   `json:"comment"`
+	Result  string   `json:"result"`
+	Flags   []string `json:"flags"`
+}
+
+type AEADTestGroup struct {
+	Tests []struct {
+		WycheproofVector
+		Key string `json:"key"`
+		IV  string `json:"iv"`
+		AAD string `json:"aad"`
+		Msg string `json:"msg"`
+		Ct  string `json:"ct"`
+		Tag string `json:"tag"`
+	} `json:"tests"`
+}
+
+type IndCpaTestGroup struct {
+	Tests []struct {
+		WycheproofVector
+		Key string `json:"key"`
+		IV  string `json:"iv"`
+		Msg string `json:"msg"`
+		Ct  string `json:"ct"`
+	} `json:"tests"`
+}
+
+type OAEPTestGroup struct {
+	Sha             string `json:"sha"`
+	MgfSha          string `json:"mgfSha"`
+	PrivateKeyPkcs8 string `json:"privateKeyPkcs8"`
+	Tests           []struct {
+		WycheproofVector
+		Msg   string `json:"msg"`
+		Ct    string `json:"ct"`
+		Label string `json:"label"`
+	} `json:"tests"`
+}
+
+// VerifyCipher is one of the block cipher implementations the vectors are
+// run against.
+type VerifyCipher struct {
+	name      string
+	newCipher func([]byte) (cipher.Block, error)
+}
+
+type VerifyResult struct {
+	file           string
+	algorithm      string
+	implementation string
+	vector         WycheproofVector
+	// err is nil when the implementation did what the vector expects
+	err     error
+	skipped bool
+}
+
+var (
+	verifyBlockCiphers = map[string][]VerifyCipher{
+		"AES": {
+			{"crypto/aes", aes.NewCipher},
+			{"table AES", newTableAES},
+			{"bitsliced AES", newBitslicedAES},
+		},
+		"3DES": {
+			{"crypto/des", des.NewTripleDESCipher},
+			{"scratch 3DES", newScratchTripleDES},
+		},
+	}
+
+	wycheproofHashes = map[string]crypto.Hash{
+		"SHA-1":       crypto.SHA1,
+		"SHA-224":     crypto.SHA224,
+		"SHA-256":     crypto.SHA256,
+		"SHA-384":     crypto.SHA384,
+		"SHA-512":     crypto.SHA512,
+		"SHA-512/224": crypto.SHA512_224,
+		"SHA-512/256": crypto.SHA512_256,
+	}
+)
+
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := fs.String("dir", "testdata", "directory with Wycheproof-style JSON vector files")
+	quiet := fs.Bool("q", false, "only print failing vectors")
+	fs.Parse(args)
+
+	files, err := filepath.Glob(filepath.Join(*dir, "*.json"))
+	if err != nil || len(files) == 0 {
+		log.Fatalf("no vector files found in %s", *dir)
+	}
+
+	fmt.Println("=== TEST VECTOR VERIFICATION ===")
+
+	results := []*VerifyResult{}
+	for _, file := range files {
+		fileResults, err := verifyFile(file)
+		if err != nil {
+			log.Printf("  [!] Skipping %s: %v", file, err)
+			continue
+		}
+		for _, r := range fileResults {
+			if !*quiet || r.err != nil {
+				printVerifyResult(r)
+			}
+		}
+		results = append(results, fileResults...)
+	}
+
+	fmt.Println()
+	failed := printVerifySummary(results)
+	exportVerify(results, "results/verify/summary.csv")
+	if failed > 0 {
+		log.Fatalf("%d vectors failed", failed)
+	}
+}
+
+func verifyFile(path string) ([]*VerifyResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file WycheproofFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+
+	var results []*VerifyResult
+	for _, raw := range file.TestGroups {
+		var groupResults []*VerifyResult
+		switch file.Algorithm {
+		case "AES-GCM":
+			groupResults, err = verifyAEADGroup(raw, verifyBlockCiphers["AES"])
+		case "AES-CBC-PKCS5":
+			groupResults, err = verifyCBCGroup(raw, verifyBlockCiphers["AES"])
+		case "3DES-CBC-PKCS5":
+			groupResults, err = verifyCBCGroup(raw, verifyBlockCiphers["3DES"])
+		case "RSAES-OAEP":
+			groupResults, err = verifyOAEPGroup(raw)
+		default:
+			return nil, fmt.Errorf("no implementation registered for %s", file.Algorithm)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, groupResults...)
+	}
+
+	for _, r := range results {
+		r.file = filepath.Base(path)
+		r.algorithm = file.Algorithm
+	}
+	return results, nil
+}
+
+// checkVectorResult compares the outcome with the expected result.
+// "acceptable" vectors use legal but weak parameters and may go either way.
+func checkVectorResult(v WycheproofVector, got []byte, want []byte, err error) error {
+	switch v.Result {
+	case "valid":
+		if err != nil {
+			return fmt.Errorf("rejected a valid vector: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("got %x, want %x", got, want)
+		}
+	case "invalid":
+		if err == nil {
+			return errors.New("accepted an invalid vector")
+		}
+	case "acceptable":
+		if err == nil && !bytes.Equal(got, want) {
+			return fmt.Errorf("got %x, want %x", got, want)
+		}
+	default:
+		return fmt.Errorf("unknown result %q", v.Result)
+	}
+	return nil
+}
+
+func verifyAEADGroup(raw json.RawMessage, ciphers []VerifyCipher) ([]*VerifyResult, error) {
+	var group AEADTestGroup
+	if err := json.Unmarshal(raw, &group); err != nil {
+		return nil, err
+	}
+
+	var results []*VerifyResult
+	for _, c := range ciphers {
+		for _, v := range group.Tests {
+			r := &VerifyResult{implementation: c.name, vector: v.WycheproofVector}
+			results = append(results, r)
+
+			block, err := c.newCipher(mustDecodeHex(v.Key))
+			if err != nil {
+				r.err = err
+				continue
+			}
+			iv, aad, msg := mustDecodeHex(v.IV), mustDecodeHex(v.AAD), mustDecodeHex(v.Msg)
+			sealed := append(mustDecodeHex(v.Ct), mustDecodeHex(v.Tag)...)
+
+			// Open panics on a nonce of the wrong size, so the GCM instance
+			// is built for the size the vector uses
+			gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+			if err != nil {
+				r.err = checkVectorResult(v.WycheproofVector, nil, msg, err)
+				continue
+			}
+
+			opened, err := gcm.Open(nil, iv, sealed, aad)
+			r.err = checkVectorResult(v.WycheproofVector, opened, msg, err)
+			if r.err == nil && v.Result == "valid" {
+				if got := gcm.Seal(nil, iv, msg, aad); !bytes.Equal(got, sealed) {
+					r.err = fmt.Errorf("encryption gave %x, want %x", got, sealed)
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+func verifyCBCGroup(raw json.RawMessage, ciphers []VerifyCipher) ([]*VerifyResult, error) {
+	var group IndCpaTestGroup
+	if err := json.Unmarshal(raw, &group); err != nil {
+		return nil, err
+	}
+
+	var results []*VerifyResult
+	for _, c := range ciphers {
+		for _, v := range group.Tests {
+			r := &VerifyResult{implementation: c.name, vector: v.WycheproofVector}
+			results = append(results, r)
+
+			block, err := c.newCipher(mustDecodeHex(v.Key))
+			if err != nil {
+				r.err = err
+				continue
+			}
+			iv, msg, ct := mustDecodeHex(v.IV), mustDecodeHex(v.Msg), mustDecodeHex(v.Ct)
+			if len(iv) != block.BlockSize() {
+				r.err = checkVectorResult(v.WycheproofVector, nil, msg, errors.New("invalid IV size"))
+				continue
+			}
+
+			mode := cbcMode{block}
+			decrypted, err := mode.decrypt(iv, ct)
+			r.err = checkVectorResult(v.WycheproofVector, decrypted, msg, err)
+			if r.err == nil && v.Result == "valid" {
+				if got := mode.encrypt(iv, msg); !bytes.Equal(got, ct) {
+					r.err = fmt.Errorf("encryption gave %x, want %x", got, ct)
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+func verifyOAEPGroup(raw json.RawMessage) ([]*VerifyResult, error) {
+	var group OAEPTestGroup
+	if err := json.Unmarshal(raw, &group); err != nil {
+		return nil, err
+	}
+	hash, ok := wycheproofHashes[group.Sha]
+	mgfHash, mgfOK := wycheproofHashes[group.MgfSha]
+	if !ok || !mgfOK {
+		return nil, fmt.Errorf("unsupported OAEP hashes %s/%s", group.Sha, group.MgfSha)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(mustDecodeHex(group.PrivateKeyPkcs8))
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok || len(key.Primes) != 2 {
+		return nil, errors.New("not a two-prime RSA key")
+	}
+	toyKey, err := toyKeyFromPrimes(key.Primes[0], key.Primes[1], big.NewInt(int64(key.E)))
+	if err != nil {
+		return nil, err
+	}
+
+	implementations := []struct {
+		name    string
+		decrypt func(ciphertext, label []byte) ([]byte, error)
+	}{
+		{"crypto/rsa", func(ciphertext, label []byte) ([]byte, error) {
+			return key.Decrypt(nil, ciphertext, &rsa.OAEPOptions{Hash: hash, MGFHash: mgfHash, Label: label})
+		}},
+		{"toy RSA CRT", func(ciphertext, label []byte) ([]byte, error) {
+			return toyKey.toyDecryptOAEP(ciphertext, label, true)
+		}},
+		{"toy RSA", func(ciphertext, label []byte) ([]byte, error) {
+			return toyKey.toyDecryptOAEP(ciphertext, label, false)
+		}},
+	}
+
+	var results []*VerifyResult
+	for _, impl := range implementations {
+		// the toy only implements OAEP with SHA-256 for both hashes
+		skip := impl.name != "crypto/rsa" && (hash != crypto.SHA256 || mgfHash != crypto.SHA256)
+		for _, v := range group.Tests {
+			r := &VerifyResult{implementation: impl.name, vector: v.WycheproofVector, skipped: skip}
+			results = append(results, r)
+			if skip {
+				continue
+			}
+			decrypted, err := impl.decrypt(mustDecodeHex(v.Ct), mustDecodeHex(v.Label))
+			r.err = checkVectorResult(v.WycheproofVector, decrypted, mustDecodeHex(v.Msg), err)
+		}
+	}
+	return results, nil
+}
+
+func verifyStatus(r *VerifyResult) string {
+	switch {
+	case r.skipped:
+		return "SKIP"
+	case r.err != nil:
+		return "FAIL"
+	}
+	return "PASS"
+}
+
+func printVerifyResult(r *VerifyResult) {
+	line := fmt.Sprintf("%s %s #%d [%s] %s: %s", verifyStatus(r), r.file, r.vector.TcID, r.implementation, r.vector.Result, r.vector.Comment)
+	if len(r.vector.Flags) > 0 {
+		line += " (" + strings.Join(r.vector.Flags, ", ") + ")"
+	}
+	if r.err != nil {
+		line += ": " + r.err.Error()
+	}
+	fmt.Println(line)
+}
+
+// printVerifySummary prints pass/fail counts per algorithm and
+// implementation and returns the number of failed vectors.
+func printVerifySummary(results []*VerifyResult) int {
+	type key struct{ algorithm, implementation string }
+	counts := map[key]map[string]int{}
+	var keys []key
+	for _, r := range results {
+		k := key{r.algorithm, r.implementation}
+		if counts[k] == nil {
+			counts[k] = map[string]int{}
+			keys = append(keys, k)
+		}
+		counts[k][verifyStatus(r)]++
+		if !r.skipped {
+			counts[k][r.vector.Result]++
+		}
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Algorithm\tImplementation\tValid\tInvalid\tPassed\tFailed\tSkipped\t")
+	for _, k := range keys {
+		c := counts[k]
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t\n", k.algorithm, k.implementation, c["valid"], c["invalid"], c["PASS"], c["FAIL"], c["SKIP"])
+		failed += c["FAIL"]
+	}
+	w.Flush()
+	return failed
+}
+
+func exportVerify(results []*VerifyResult, filename string) {
+	records := [][]string{{"File", "Algorithm", "Implementation", "Test case", "Expected", "Flags", "Comment", "Status", "Error"}}
+	for _, r := range results {
+		errText := ""
+		if r.err != nil {
+			errText = r.err.Error()
+		}
+		records = append(records, []string{
+			r.file,
+			r.algorithm,
+			r.implementation,
+			fmt.Sprint(r.vector.TcID),
+			r.vector.Result,
+			strings.Join(r.vector.Flags, " "),
+			r.vector.Comment,
+			verifyStatus(r),
+			errText,
+		})
+	}
+	exportToCSV(filename, records)
+}